@@ -0,0 +1,29 @@
+package main
+
+import (
+	"art/internal/drive"
+	"context"
+	"flag"
+	"log"
+	"os"
+)
+
+const (
+	defaultDriveCredentials = "ServiceAccountCred.json"
+	defaultDriveToken       = "token.json"
+)
+
+// driveAuth runs `art drive-auth`, the one-time OAuth consent flow creating
+// the token the server uses with OAuth client credentials.
+func driveAuth(args []string) {
+	flags := flag.NewFlagSet("drive-auth", flag.ExitOnError)
+	creds := drive.Credentials{}
+	flags.StringVar(&creds.File, "drive-credentials", defaultDriveCredentials, "Drive OAuth client secret")
+	flags.StringVar(&creds.TokenFile, "drive-token", defaultDriveToken, "file to store the Drive OAuth token in")
+	flags.Parse(args)
+
+	err := creds.Bootstrap(context.Background(), os.Stdin, os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
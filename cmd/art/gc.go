@@ -0,0 +1,193 @@
+package main
+
+import (
+	users "art/internal/models"
+	"art/internal/storage"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// gc runs `art gc`, which reports image folders no painting, artist or
+// collection references, and references to folders that do not exist. With
+// -delete it also removes the orphaned folders and clears the dangling
+// references. The private originals of watermarked images are checked the
+// same way. The folders of unfinished photo jobs count as referenced.
+func gc(args []string) {
+	flags := flag.NewFlagSet("gc", flag.ExitOnError)
+	opts := options{}
+	opts.register(flags)
+	remove := flags.Bool("delete", false, "delete orphaned folders and clear dangling references instead of only reporting them")
+	grace := flags.Duration("grace", time.Hour, "ignore folders younger than this, which may belong to uploads in progress")
+	flags.Parse(args)
+
+	// Memory state starts empty, so every folder would look orphaned.
+	if opts.state == "memory" {
+		log.Fatal("gc needs the state the server keeps, memory state cannot be audited")
+	}
+
+	ctx := context.Background()
+	st, err := opts.openState()
+	if err != nil {
+		log.Fatal(err)
+	}
+	imageStore, err := opts.openImages(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	uploads, err := opts.uploads()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sets := []imageSet{{
+		name:   opts.images + " image store",
+		store:  imageStore,
+		folder: func(p users.Photos) string { return p.FolderId },
+	}}
+	if uploads.Originals != nil {
+		sets = append(sets, imageSet{
+			name:   "originals",
+			store:  uploads.Originals,
+			folder: func(p users.Photos) string { return p.OriginalsFolder },
+			field:  "originalsFolder",
+		})
+	}
+
+	failed := false
+	for _, set := range sets {
+		lister, ok := set.store.(storage.Lister)
+		if !ok {
+			log.Fatalf("The %s cannot list its folders", set.name)
+		}
+		refs, err := references(ctx, st, set.folder)
+		if err != nil {
+			log.Fatal(err)
+		}
+		report, err := storage.Audit(ctx, lister, refs, *grace)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("%s:\n", set.name)
+		for _, f := range report.Orphans {
+			fmt.Printf("orphan\t%s\tcreated %s\n", f.Name, f.Created.Format(time.RFC3339))
+		}
+		for _, ref := range report.Dangling {
+			fmt.Printf("dangling\t%s %s\t%s\n", ref.Kind, ref.ID, ref.Folder)
+		}
+		fmt.Printf("%d orphaned folders, %d dangling references, %d recent folders skipped\n", len(report.Orphans), len(report.Dangling), report.Recent)
+
+		if !*remove {
+			continue
+		}
+		for _, f := range report.Orphans {
+			err := set.store.Delete(ctx, f.Name)
+			if err != nil {
+				log.Printf("Could not delete folder %s: %v", f.Name, err)
+				failed = true
+			}
+		}
+		for _, ref := range report.Dangling {
+			err := clearReference(ctx, st, ref, set.field)
+			if err != nil {
+				log.Printf("Could not clear %s %s: %v", ref.Kind, ref.ID, err)
+				failed = true
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+	if *remove {
+		fmt.Println("Deleted orphaned folders and cleared dangling references")
+	}
+}
+
+// imageSet is a store of image folders and how records refer to them.
+type imageSet struct {
+	name   string
+	store  storage.ImageStore
+	folder func(users.Photos) string
+	// field is the reference cleared when its folder is missing, or all
+	// the photos if empty.
+	field string
+}
+
+// references lists the image folders referenced by paintings, artists and
+// collections, as read from their photos by folder, and the folders
+// unfinished photo jobs store images in.
+func references(ctx context.Context, st states, folder func(users.Photos) string) ([]storage.Reference, error) {
+	var refs []storage.Reference
+	add := func(kind string, id primitive.ObjectID, photos users.Photos) {
+		if name := folder(photos); name != "" {
+			refs = append(refs, storage.Reference{Kind: kind, ID: id.Hex(), Folder: name})
+		}
+	}
+
+	paintings, _, err := st.gallery.List(ctx, users.PaintingQuery{})
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range paintings {
+		add("painting", p.ID, p.Photos)
+	}
+
+	artists, err := st.artists.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range artists {
+		add("artist", a.ID, a.Portrait)
+	}
+
+	collections, err := st.collections.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range collections {
+		add("collection", c.ID, c.Cover)
+	}
+
+	jobs, err := st.jobs.Unfinished(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, j := range jobs {
+		refs = append(refs, storage.Reference{Kind: "job", ID: j.ID.Hex(), Folder: j.Folder, Pending: true})
+	}
+
+	return refs, nil
+}
+
+// clearReference unsets field of the photos ref points at, or all of them if
+// field is empty.
+func clearReference(ctx context.Context, st states, ref storage.Reference, field string) error {
+	id, err := primitive.ObjectIDFromHex(ref.ID)
+	if err != nil {
+		return err
+	}
+	unset := func(photos string) bson.M {
+		if field != "" {
+			photos += "." + field
+		}
+		return bson.M{"$unset": bson.M{photos: ""}}
+	}
+
+	switch ref.Kind {
+	case "painting":
+		return st.gallery.Update(ctx, id, unset("photos"))
+	case "artist":
+		return st.artists.Update(ctx, id, unset("portrait"))
+	case "collection":
+		return st.collections.Update(ctx, id, unset("cover"))
+	default:
+		return fmt.Errorf("unknown reference kind %q", ref.Kind)
+	}
+}
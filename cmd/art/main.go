@@ -3,24 +3,127 @@ package main
 import (
 	"art/internal/api"
 	"art/internal/controllers"
-	"art/internal/db"
 	users "art/internal/models"
+	"art/internal/photoprocessor"
+	"art/internal/storage"
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
-	mongo := db.NewMongoGalleryState()
-	mongoUsers := db.NewMongoUserState(mongo.DB)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "drive-auth":
+			driveAuth(os.Args[2:])
+			return
+		case "gc":
+			gc(os.Args[2:])
+			return
+		case "migrate":
+			migrate(os.Args[2:])
+			return
+		}
+	}
 
-	gl := users.NewGallery(mongo)
-	glc := &controllers.GalleryController{Gallery: gl}
+	opts := options{}
+	opts.register(flag.CommandLine)
+	flag.Parse()
 
-	us := users.NewUsers(mongoUsers)
-	usc := &controllers.UserControllers{Users: us}
+	uploads, err := opts.uploads()
+	if err != nil {
+		log.Fatal(err)
+	}
+	st, err := opts.openState()
+	if err != nil {
+		log.Fatal(err)
+	}
+	requireMigrated(context.Background(), st)
+	imageStore, err := opts.openImages(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	r := api.NewRouter(glc, usc)
-	err := http.ListenAndServe("localhost:8080", r)
+	// Workspaces this old were left behind by a crash mid-upload, as no upload
+	// takes that long. Those of queued jobs are kept in the jobs directory
+	// however old, so it must not be the one swept.
+	if within(opts.jobsDir, os.TempDir()) && within(os.TempDir(), opts.jobsDir) {
+		log.Fatal("The jobs directory must not be the temporary directory, whose old upload workspaces are removed")
+	}
+	swept, err := photoprocessor.SweepWorkspaces("", 6*time.Hour)
 	if err != nil {
-		return
+		log.Println(err)
+	}
+	if swept > 0 {
+		log.Printf("Removed %d stale upload workspaces", swept)
+	}
+
+	gl := users.NewGallery(st.gallery)
+	us := users.NewUsers(st.users)
+	ms := users.NewMaterials(st.materials, st.gallery)
+	as := users.NewArtists(st.artists, st.gallery)
+	cs := users.NewCollections(st.collections, st.gallery)
+
+	js := users.NewJobs(st.jobs)
+
+	// A shutdown signal cancels ctx, interrupting photo jobs, which are
+	// resumed on the next start.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	photoJobs := &controllers.PhotoJobs{Jobs: js, Gallery: gl, Images: imageStore, Uploads: uploads, Dir: opts.jobsDir, Workers: opts.jobWorkers}
+	err = photoJobs.Start(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	glc := &controllers.GalleryController{Gallery: gl, Users: us, Materials: ms, Artists: as, Collections: cs, Images: imageStore, Uploads: uploads, PhotoJobs: photoJobs}
+	usc := &controllers.UserControllers{Users: us}
+	mc := &controllers.MaterialController{Materials: ms}
+	ac := &controllers.ArtistController{Artists: as, Collections: cs, Images: imageStore, Uploads: uploads}
+	cc := &controllers.CollectionController{Collections: cs, Images: imageStore, Uploads: uploads}
+	jc := &controllers.JobController{Jobs: js}
+
+	r := api.NewRouter(glc, usc, mc, ac, cc, jc)
+	if server, ok := imageStore.(storage.Server); ok {
+		r.PathPrefix("/images/").Handler(http.StripPrefix("/images/", server.Handler())).Methods("GET")
+	}
+
+	// Requests get a context of their own, so that in-flight requests finish
+	// during shutdown. It is only cancelled once they had the time to.
+	base, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	server := &http.Server{
+		Addr:        "localhost:8080",
+		Handler:     r,
+		BaseContext: func(net.Listener) context.Context { return base },
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err := server.Shutdown(shutdownCtx)
+		if err != nil {
+			log.Println(err)
+		}
+		cancelRequests()
+	}()
+
+	err = server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
 	}
+	// Requests still being served could submit jobs: wait for them first.
+	<-done
+	photoJobs.Wait()
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"art/internal/db"
+	users "art/internal/models"
+	"context"
+	"flag"
+	"log"
+)
+
+// migrate runs `art migrate`, which converts the data stored by earlier
+// versions of the server: it types legacy sizes and fills the materials
+// catalogue from paintings. Data it cannot convert is kept aside and
+// reported, never dropped. Running it again changes nothing.
+func migrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	opts := options{}
+	opts.register(flags)
+	flags.Parse(args)
+
+	if opts.state == "memory" {
+		log.Fatal("migrate needs the state the server keeps, memory state holds nothing to migrate")
+	}
+
+	ctx := context.Background()
+	st, err := opts.openState()
+	if err != nil {
+		log.Fatal(err)
+	}
+	gallery, ok := st.gallery.(*db.MongoGalleryState)
+	if !ok {
+		log.Fatalf("Cannot migrate %s state", opts.state)
+	}
+
+	migrated, unreadable, err := gallery.MigrateSizes(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Converted the sizes of %d paintings", migrated)
+	for _, id := range unreadable {
+		log.Printf("Painting %s: size could not be read, it was kept as legacySize", id.Hex())
+	}
+
+	// Paintings are validated against the materials catalogue, which
+	// paintings stored before it existed are not in.
+	imported, skipped, err := users.NewMaterials(st.materials, st.gallery).Import(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Added %d materials of paintings to the catalogue", len(imported))
+	for _, m := range skipped {
+		log.Printf("Material %q (%q, %q) of paintings is not a valid catalogue entry, add it by hand", m.ID, m.EN, m.UKR)
+	}
+}
+
+// requireMigrated stops the server if the state holds data `art migrate` has
+// yet to convert, as the paintings concerned could not be read.
+func requireMigrated(ctx context.Context, st states) {
+	gallery, ok := st.gallery.(*db.MongoGalleryState)
+	if !ok {
+		return
+	}
+	n, err := gallery.LegacySizes(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if n > 0 {
+		log.Fatalf("%d paintings have sizes stored by an earlier version, run `art migrate` first", n)
+	}
+}
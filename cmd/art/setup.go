@@ -0,0 +1,184 @@
+package main
+
+import (
+	"art/internal/controllers"
+	"art/internal/db"
+	"art/internal/drive"
+	users "art/internal/models"
+	"art/internal/photoprocessor"
+	"art/internal/storage"
+	"context"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// options are the flags choosing the storage backends, shared by the server
+// and the commands maintaining its data.
+type options struct {
+	state         string
+	images        string
+	imagesDir     string
+	imagesURL     string
+	uploadWorkers int
+	renditions    string
+	limits        photoprocessor.Limits
+	imageFormats  string
+	keepMetadata  string
+	watermark     photoprocessor.Watermark
+	watermarkLogo string
+	watermarkOn   string
+	originalsDir  string
+	jobsDir       string
+	jobWorkers    int
+	drive         drive.Config
+	s3            storage.S3Config
+}
+
+func (o *options) register(flags *flag.FlagSet) {
+	flags.StringVar(&o.state, "state", "mongo", "storage backend for paintings and users: mongo or memory")
+	flags.StringVar(&o.images, "images", "drive", "storage backend for images: drive, local or s3")
+	flags.StringVar(&o.imagesDir, "images-dir", "images", "directory of the local image store")
+	flags.StringVar(&o.imagesURL, "images-url", "http://localhost:8080/images", "base URL images served by the art server are linked to")
+	flags.IntVar(&o.uploadWorkers, "upload-workers", storage.DefaultUploadWorkers, "number of images of one item uploaded at once")
+	flags.StringVar(&o.renditions, "renditions", "thumb=300,medium=800,full=1500,original", "sizes images are stored in, as name=longSide or name for the original")
+	flags.IntVar(&o.limits.MaxFiles, "max-images", photoprocessor.DefaultLimits.MaxFiles, "number of images accepted in one upload")
+	flags.Int64Var(&o.limits.MaxBytes, "max-image-bytes", photoprocessor.DefaultLimits.MaxBytes, "size of the largest image file accepted")
+	flags.IntVar(&o.limits.MaxSide, "max-image-side", photoprocessor.DefaultLimits.MaxSide, "largest image width or height accepted, in pixels")
+	flags.StringVar(&o.imageFormats, "image-formats", strings.Join(photoprocessor.DefaultLimits.Formats, ","), "image formats accepted: jpeg, png, gif, tiff or bmp")
+	flags.StringVar(&o.keepMetadata, "keep-metadata", "", "EXIF fields published with images, of captured and camera; all others are stripped")
+	flags.StringVar(&o.watermark.Text, "watermark-text", "", "text overlaid on published images, e.g. a copyright notice")
+	flags.StringVar(&o.watermarkLogo, "watermark-logo", "", "PNG logo overlaid on published images instead of a text")
+	flags.StringVar(&o.watermark.Position, "watermark-position", photoprocessor.BottomRight, "where the watermark is placed: top-left, top-right, bottom-left, bottom-right or center")
+	flags.Float64Var(&o.watermark.Opacity, "watermark-opacity", 0.5, "opacity of the watermark, from 0 to 1")
+	flags.Float64Var(&o.watermark.Scale, "watermark-scale", 0.2, "largest fraction of the image width and height the watermark covers")
+	flags.StringVar(&o.watermarkOn, "watermark-renditions", strings.Join(photoprocessor.DefaultWatermarkRenditions, ","), "renditions the watermark is overlaid on")
+	flags.StringVar(&o.originalsDir, "originals-dir", "originals", "private directory originals are kept in when images are watermarked")
+	flags.StringVar(&o.jobsDir, "jobs-dir", "jobs", "directory uploads are kept in until their images are processed; must survive restarts")
+	flags.IntVar(&o.jobWorkers, "job-workers", controllers.DefaultJobWorkers, "number of uploads processed at once")
+	flags.StringVar(&o.drive.Credentials.File, "drive-credentials", defaultDriveCredentials, "Drive service account key or OAuth client secret")
+	flags.StringVar(&o.drive.Credentials.TokenFile, "drive-token", defaultDriveToken, "Drive OAuth token created by `art drive-auth`")
+	flags.StringVar(&o.drive.RootFolderID, "drive-root", drive.DefaultRootFolderID, "ID of the Drive folder images are uploaded to")
+	flags.StringVar(&o.s3.Endpoint, "s3-endpoint", "localhost:9000", "host and port of the S3-compatible image store")
+	flags.StringVar(&o.s3.Region, "s3-region", "", "region of the S3 bucket")
+	flags.StringVar(&o.s3.Bucket, "s3-bucket", "paintings", "bucket of the S3 image store")
+	flags.StringVar(&o.s3.Prefix, "s3-prefix", "", "key prefix of images in the S3 bucket")
+	flags.BoolVar(&o.s3.Secure, "s3-ssl", false, "connect to the S3 endpoint over HTTPS")
+	flags.BoolVar(&o.s3.Public, "s3-public", false, "upload images with a public-read ACL and link to them directly")
+	flags.StringVar(&o.s3.PublicURL, "s3-public-url", "", "base URL of public images, e.g. a CDN in front of the bucket")
+}
+
+// states holds the state backends of every entity.
+type states struct {
+	gallery     users.GalleryState
+	users       users.UserState
+	materials   users.MaterialState
+	artists     users.ArtistState
+	collections users.CollectionState
+	jobs        users.JobState
+}
+
+func (o *options) openState() (states, error) {
+	switch o.state {
+	case "mongo":
+		mongo := db.NewMongoGalleryState()
+		return states{
+			gallery:     mongo,
+			users:       db.NewMongoUserState(mongo.DB),
+			materials:   db.NewMongoMaterialState(mongo.DB),
+			artists:     db.NewMongoArtistState(mongo.DB),
+			collections: db.NewMongoCollectionState(mongo.DB),
+			jobs:        db.NewMongoJobState(mongo.DB),
+		}, nil
+	case "memory":
+		return states{
+			gallery:     db.NewMemoryGalleryState(),
+			users:       db.NewMemoryUserState(),
+			materials:   db.NewMemoryMaterialState(),
+			artists:     db.NewMemoryArtistState(),
+			collections: db.NewMemoryCollectionState(),
+			jobs:        db.NewMemoryJobState(),
+		}, nil
+	default:
+		return states{}, fmt.Errorf("Unknown state backend %q", o.state)
+	}
+}
+
+func (o *options) uploads() (controllers.Uploads, error) {
+	renditions, err := photoprocessor.ParseRenditions(o.renditions)
+	if err != nil {
+		return controllers.Uploads{}, err
+	}
+	limits := o.limits
+	limits.Formats, err = photoprocessor.ParseFormats(o.imageFormats)
+	if err != nil {
+		return controllers.Uploads{}, err
+	}
+	keep, err := photoprocessor.ParseMetadataFields(o.keepMetadata)
+	if err != nil {
+		return controllers.Uploads{}, err
+	}
+	uploads := controllers.Uploads{Workers: o.uploadWorkers, Renditions: renditions, Limits: &limits, KeepMetadata: keep}
+	if o.watermark.Text == "" && o.watermarkLogo == "" {
+		return uploads, nil
+	}
+
+	watermark := o.watermark
+	if o.watermarkLogo != "" {
+		watermark.Logo, err = photoprocessor.LoadLogo(o.watermarkLogo)
+		if err != nil {
+			return controllers.Uploads{}, err
+		}
+	}
+	watermark.Renditions = strings.Split(o.watermarkOn, ",")
+	err = watermark.Check(renditions)
+	if err != nil {
+		return controllers.Uploads{}, err
+	}
+	uploads.Watermark = &watermark
+
+	// The originals are kept unwatermarked, in a directory the server does
+	// not serve.
+	if o.images == "local" && within(o.originalsDir, o.imagesDir) {
+		return controllers.Uploads{}, fmt.Errorf("The originals directory %s must be outside the served images directory", o.originalsDir)
+	}
+	uploads.Originals, err = storage.NewLocalStore(o.originalsDir, "")
+	if err != nil {
+		return controllers.Uploads{}, err
+	}
+	return uploads, nil
+}
+
+// within reports whether the directory dir is root or inside it.
+func within(dir string, root string) bool {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func (o *options) openImages(ctx context.Context) (storage.ImageStore, error) {
+	switch o.images {
+	case "drive":
+		driveClient, err := drive.NewClient(ctx, o.drive)
+		if err != nil {
+			return nil, err
+		}
+		return storage.NewDriveStore(driveClient), nil
+	case "local":
+		return storage.NewLocalStore(o.imagesDir, o.imagesURL)
+	case "s3":
+		s3Config := o.s3
+		s3Config.BaseURL = o.imagesURL
+		return storage.NewS3Store(ctx, s3Config)
+	default:
+		return nil, fmt.Errorf("Unknown image store %q", o.images)
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"github.com/gorilla/mux"
 )
 
-func NewRouter(glc *controllers.GalleryController, usc *controllers.UserControllers) *mux.Router {
+func NewRouter(glc *controllers.GalleryController, usc *controllers.UserControllers, mc *controllers.MaterialController, ac *controllers.ArtistController, cc *controllers.CollectionController, jc *controllers.JobController) *mux.Router {
 	r := mux.NewRouter()
 
 	r.HandleFunc("/register", usc.Register).Methods("POST")
@@ -16,10 +16,35 @@ func NewRouter(glc *controllers.GalleryController, usc *controllers.UserControll
 	//r.HandleFunc("/paintings", middleware.Authorize(glc.ListProducts, usc)).Methods("GET")
 
 	r.HandleFunc("/paintings/add", glc.AddPainting).Methods("POST")
+	r.HandleFunc("/paintings/search", glc.SearchPaintings).Methods("GET")
+	r.HandleFunc("/paintings/facets", glc.ListFacets).Methods("GET")
 
 	r.HandleFunc("/paintings/{id}", glc.GetOnePainting).Methods("GET")
 	r.HandleFunc("/paintings/{id}", glc.DeletePainting).Methods("DELETE")
 	r.HandleFunc("/paintings/{id}", glc.UpdatePainting).Methods("PUT")
 
+	r.HandleFunc("/paintings/{id}/availability", glc.AvailabilityHistory).Methods("GET")
+	r.HandleFunc("/paintings/{id}/availability", middleware.ApplyMiddleware(glc.TransitionAvailability, usc, middleware.Authorize)).Methods("POST")
+
+	r.HandleFunc("/materials", mc.ListMaterials).Methods("GET")
+	r.HandleFunc("/materials", mc.AddMaterial).Methods("POST")
+	r.HandleFunc("/materials/{id}", mc.GetOneMaterial).Methods("GET")
+	r.HandleFunc("/materials/{id}", mc.UpdateMaterial).Methods("PUT")
+	r.HandleFunc("/materials/{id}", mc.DeleteMaterial).Methods("DELETE")
+
+	r.HandleFunc("/artists", ac.ListArtists).Methods("GET")
+	r.HandleFunc("/artists", ac.AddArtist).Methods("POST")
+	r.HandleFunc("/artists/{id}", ac.GetOneArtist).Methods("GET")
+	r.HandleFunc("/artists/{id}", ac.UpdateArtist).Methods("PUT")
+	r.HandleFunc("/artists/{id}", ac.DeleteArtist).Methods("DELETE")
+
+	r.HandleFunc("/collections", cc.ListCollections).Methods("GET")
+	r.HandleFunc("/collections", cc.AddCollection).Methods("POST")
+	r.HandleFunc("/collections/{id}", cc.GetOneCollection).Methods("GET")
+	r.HandleFunc("/collections/{id}", cc.UpdateCollection).Methods("PUT")
+	r.HandleFunc("/collections/{id}", cc.DeleteCollection).Methods("DELETE")
+
+	r.HandleFunc("/jobs/{id}", jc.GetJob).Methods("GET")
+
 	return r
 }
@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"art/internal/models"
+	"art/internal/storage"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ArtistController struct {
+	Artists     *models.Artists
+	Collections *models.Collections
+	Images      storage.ImageStore
+	Uploads     Uploads
+}
+
+// artistDetail is an artist together with one page of their works.
+type artistDetail struct {
+	Artist models.Artist     `json:"artist"`
+	Works  []models.Painting `json:"works"`
+}
+
+func (w *ArtistController) ListArtists(res http.ResponseWriter, req *http.Request) {
+	artists, err := w.Artists.List(req.Context())
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, Response{Data: artists})
+}
+
+// GetOneArtist returns the artist and their works, paginated, filtered and
+// sorted with the same parameters as GET /paintings.
+func (w *ArtistController) GetOneArtist(res http.ResponseWriter, req *http.Request) {
+	id, err := primitive.ObjectIDFromHex(mux.Vars(req)["id"])
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+	q, err := parsePaintingQuery(req)
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+	unit, err := parseUnit(req)
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	artist, err := w.Artists.One(req.Context(), id)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	q.Filter, err = w.Collections.Scope(req.Context(), q.Filter)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	works, total, err := w.Artists.Works(req.Context(), id, q)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	for i := range works {
+		convertSize(&works[i], unit)
+	}
+
+	writeJSON(res, http.StatusOK, Response{Data: artistDetail{Artist: artist, Works: works}, Meta: newPageMeta(req, q, total)})
+}
+
+func (w *ArtistController) AddArtist(res http.ResponseWriter, req *http.Request) {
+	err := req.ParseMultipartForm(10 << 20)
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	artist := models.Artist{
+		ID:      primitive.NewObjectID(),
+		Name:    req.FormValue("name"),
+		NameUkr: req.FormValue("nameUkr"),
+		Bio:     req.FormValue("bio"),
+		BioUkr:  req.FormValue("bioUkr"),
+	}
+	if yearStr := req.FormValue("birthYear"); yearStr != "" {
+		artist.BirthYear, err = strconv.Atoi(yearStr)
+		if err != nil {
+			writeJSON(res, http.StatusBadRequest, Response{Error: fmt.Sprintf("Invalid birthYear value: %q", yearStr)})
+			return
+		}
+	}
+	err = artist.Validate()
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	var work unitOfWork
+	defer work.rollback(req.Context())
+
+	artist.Portrait, _, err = replaceImage(req, &work, w.Images, w.Uploads, "portrait", "artist", models.Photos{})
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	id, err := w.Artists.Add(req.Context(), artist)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	work.commit(req.Context())
+
+	writeJSON(res, http.StatusCreated, Response{Data: id.Hex(), Message: "Artist created successfully"})
+}
+
+func (w *ArtistController) UpdateArtist(res http.ResponseWriter, req *http.Request) {
+	err := req.ParseMultipartForm(10 << 20)
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(mux.Vars(req)["id"])
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	artist, err := w.Artists.One(req.Context(), id)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	update := bson.M{}
+	for field, value := range map[string]*string{
+		"name":    &artist.Name,
+		"nameUkr": &artist.NameUkr,
+		"bio":     &artist.Bio,
+		"bioUkr":  &artist.BioUkr,
+	} {
+		if v := req.FormValue(field); v != "" {
+			*value = v
+			update[field] = v
+		}
+	}
+	if yearStr := req.FormValue("birthYear"); yearStr != "" {
+		artist.BirthYear, err = strconv.Atoi(yearStr)
+		if err != nil {
+			writeJSON(res, http.StatusBadRequest, Response{Error: fmt.Sprintf("Invalid birthYear value: %q", yearStr)})
+			return
+		}
+		update["birthYear"] = artist.BirthYear
+	}
+	err = artist.Validate()
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	var work unitOfWork
+	defer work.rollback(req.Context())
+
+	portrait, ok, err := replaceImage(req, &work, w.Images, w.Uploads, "portrait", "artist", artist.Portrait)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	if ok {
+		update["portrait"] = portrait
+	}
+
+	if len(update) > 0 {
+		err = w.Artists.Update(req.Context(), id, bson.M{"$set": update})
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+	}
+
+	work.commit(req.Context())
+
+	writeJSON(res, http.StatusOK, Response{Message: "Artist updated successfully"})
+}
+
+func (w *ArtistController) DeleteArtist(res http.ResponseWriter, req *http.Request) {
+	id, err := primitive.ObjectIDFromHex(mux.Vars(req)["id"])
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	artist, err := w.Artists.One(req.Context(), id)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	err = w.Artists.Delete(req.Context(), id)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	err = deletePhotos(w.Images, w.Uploads, artist.Portrait)(req.Context())
+	if err != nil {
+		log.Println(err)
+	}
+
+	writeJSON(res, http.StatusOK, Response{Message: "Artist deleted successfully"})
+}
@@ -0,0 +1,132 @@
+package controllers_test
+
+import (
+	"art/internal/controllers"
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"testing"
+)
+
+// imageRequest builds a multipart request sending fields and one JPEG image
+// in the form field file.
+func imageRequest(t *testing.T, method string, url string, fields map[string]string, file string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for name, value := range fields {
+		err := w.WriteField(name, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	part, err := w.CreateFormFile(file, "portrait.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = part.Write(controllers.JPEGFixture(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(method, url, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+type imageOwner struct {
+	Portrait struct{ FolderId string }
+	Cover    struct{ FolderId string }
+}
+
+func TestArtistPortraitReplaced(t *testing.T) {
+	server := newTestServer(t)
+
+	var id string
+	status, res := do(t, imageRequest(t, "POST", server.URL+"/artists", map[string]string{"name": "Vincent"}, "portrait"), &id)
+	if status != http.StatusCreated {
+		t.Fatalf("create: %d %+v", status, res)
+	}
+
+	var artist struct {
+		Artist imageOwner `json:"artist"`
+	}
+	do(t, newRequest(t, "GET", server.URL+"/artists/"+id, nil), &artist)
+	old := artist.Artist.Portrait.FolderId
+	if old == "" || !controllers.FolderExists(t, server.Images, old) {
+		t.Fatalf("portrait folder %q was not stored", old)
+	}
+
+	// An invalid update stores nothing and keeps the portrait.
+	status, _ = do(t, imageRequest(t, "PUT", server.URL+"/artists/"+id, map[string]string{"birthYear": "soon"}, "portrait"), nil)
+	if status != http.StatusBadRequest {
+		t.Fatalf("invalid update: got %d, want 400", status)
+	}
+	entries, err := os.ReadDir(server.Images)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("%d image folders after an invalid update, want 1", len(entries))
+	}
+
+	status, res = do(t, imageRequest(t, "PUT", server.URL+"/artists/"+id, nil, "portrait"), nil)
+	if status != http.StatusOK {
+		t.Fatalf("update: %d %+v", status, res)
+	}
+	do(t, newRequest(t, "GET", server.URL+"/artists/"+id, nil), &artist)
+	replacement := artist.Artist.Portrait.FolderId
+	if replacement == old || !controllers.FolderExists(t, server.Images, replacement) {
+		t.Fatalf("portrait folder %q was not replaced", replacement)
+	}
+	if controllers.FolderExists(t, server.Images, old) {
+		t.Errorf("replaced portrait folder %q was not deleted", old)
+	}
+
+	status, _ = do(t, newRequest(t, "DELETE", server.URL+"/artists/"+id, nil), nil)
+	if status != http.StatusOK {
+		t.Fatalf("delete: got %d", status)
+	}
+	if controllers.FolderExists(t, server.Images, replacement) {
+		t.Errorf("portrait folder %q of a deleted artist was kept", replacement)
+	}
+}
+
+func TestCollectionCoverReplaced(t *testing.T) {
+	server := newTestServer(t)
+
+	var id string
+	status, res := do(t, imageRequest(t, "POST", server.URL+"/collections", map[string]string{"title": "Arles", "kind": "series"}, "cover"), &id)
+	if status != http.StatusCreated {
+		t.Fatalf("create: %d %+v", status, res)
+	}
+
+	var collection struct {
+		Collection imageOwner `json:"collection"`
+	}
+	do(t, newRequest(t, "GET", server.URL+"/collections/"+id, nil), &collection)
+	old := collection.Collection.Cover.FolderId
+	if old == "" || !controllers.FolderExists(t, server.Images, old) {
+		t.Fatalf("cover folder %q was not stored", old)
+	}
+
+	status, res = do(t, imageRequest(t, "PUT", server.URL+"/collections/"+id, nil, "cover"), nil)
+	if status != http.StatusOK {
+		t.Fatalf("update: %d %+v", status, res)
+	}
+	do(t, newRequest(t, "GET", server.URL+"/collections/"+id, nil), &collection)
+	if replacement := collection.Collection.Cover.FolderId; replacement == old || !controllers.FolderExists(t, server.Images, replacement) {
+		t.Fatalf("cover folder %q was not replaced", replacement)
+	}
+	if controllers.FolderExists(t, server.Images, old) {
+		t.Errorf("replaced cover folder %q was not deleted", old)
+	}
+}
@@ -56,7 +56,7 @@ func (services *UserControllers) Register(w http.ResponseWriter, r *http.Request
 		Password: hashedPassword,
 	}
 
-	err = services.Users.Register(user)
+	err = services.Users.Register(r.Context(), user)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -96,12 +96,12 @@ func (services *UserControllers) SignIn(w http.ResponseWriter, r *http.Request)
 		Password: hashedPassword,
 	}
 
-	user, err = services.Users.Login(user)
+	user, err = services.Users.Login(r.Context(), user)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	services.Users.SetAuthenticated(string(newAttempt.Login), true)
-	*r = *r.Clone(context.WithValue(r.Context(), types.CONTEXT_AUTH_KEY, true))
+	ctx := context.WithValue(r.Context(), types.CONTEXT_AUTH_KEY, true)
+	*r = *r.Clone(context.WithValue(ctx, types.CONTEXT_LOGIN_KEY, user.Login))
 }
@@ -0,0 +1,244 @@
+package controllers
+
+import (
+	"art/internal/models"
+	"art/internal/storage"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CollectionController struct {
+	Collections *models.Collections
+	Images      storage.ImageStore
+	Uploads     Uploads
+}
+
+// collectionDetail is a collection together with its paintings in collection
+// order.
+type collectionDetail struct {
+	Collection models.Collection `json:"collection"`
+	Paintings  []models.Painting `json:"paintings"`
+}
+
+func (w *CollectionController) ListCollections(res http.ResponseWriter, req *http.Request) {
+	collections, err := w.Collections.List(req.Context())
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, Response{Data: collections})
+}
+
+func (w *CollectionController) GetOneCollection(res http.ResponseWriter, req *http.Request) {
+	id, err := primitive.ObjectIDFromHex(mux.Vars(req)["id"])
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+	unit, err := parseUnit(req)
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	collection, err := w.Collections.One(req.Context(), id)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	paintings, err := w.Collections.Paintings(req.Context(), collection)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	for i := range paintings {
+		convertSize(&paintings[i], unit)
+	}
+
+	writeJSON(res, http.StatusOK, Response{Data: collectionDetail{Collection: collection, Paintings: paintings}})
+}
+
+func (w *CollectionController) AddCollection(res http.ResponseWriter, req *http.Request) {
+	err := req.ParseMultipartForm(10 << 20)
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	collection := models.Collection{ID: primitive.NewObjectID(), PaintingIDs: []primitive.ObjectID{}}
+	_, err = parseCollectionForm(req, &collection)
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+	err = collection.Validate()
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	var work unitOfWork
+	defer work.rollback(req.Context())
+
+	collection.Cover, _, err = replaceImage(req, &work, w.Images, w.Uploads, "cover", "collection", models.Photos{})
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	id, err := w.Collections.Add(req.Context(), collection)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	work.commit(req.Context())
+
+	writeJSON(res, http.StatusCreated, Response{Data: id.Hex(), Message: "Collection created successfully"})
+}
+
+func (w *CollectionController) UpdateCollection(res http.ResponseWriter, req *http.Request) {
+	err := req.ParseMultipartForm(10 << 20)
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+	id, err := primitive.ObjectIDFromHex(mux.Vars(req)["id"])
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	collection, err := w.Collections.One(req.Context(), id)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	update, err := parseCollectionForm(req, &collection)
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+	err = collection.Validate()
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	var work unitOfWork
+	defer work.rollback(req.Context())
+
+	cover, ok, err := replaceImage(req, &work, w.Images, w.Uploads, "cover", "collection", collection.Cover)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	if ok {
+		update["cover"] = cover
+	}
+
+	if len(update) > 0 {
+		err = w.Collections.Update(req.Context(), id, bson.M{"$set": update})
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+	}
+
+	work.commit(req.Context())
+
+	writeJSON(res, http.StatusOK, Response{Message: "Collection updated successfully"})
+}
+
+func (w *CollectionController) DeleteCollection(res http.ResponseWriter, req *http.Request) {
+	id, err := primitive.ObjectIDFromHex(mux.Vars(req)["id"])
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	collection, err := w.Collections.One(req.Context(), id)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	err = w.Collections.Delete(req.Context(), id)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	err = deletePhotos(w.Images, w.Uploads, collection.Cover)(req.Context())
+	if err != nil {
+		log.Println(err)
+	}
+
+	writeJSON(res, http.StatusOK, Response{Message: "Collection deleted successfully"})
+}
+
+// parseCollectionForm applies the form values present in req to collection
+// and returns them as a Mongo $set document. Dates are RFC 3339 and
+// paintings is a JSON list of painting IDs in collection order.
+func parseCollectionForm(req *http.Request, collection *models.Collection) (bson.M, error) {
+	update := bson.M{}
+	for field, value := range map[string]*string{
+		"kind":           &collection.Kind,
+		"title":          &collection.Title,
+		"titleUkr":       &collection.TitleUkr,
+		"description":    &collection.Description,
+		"descriptionUkr": &collection.DescriptionUkr,
+		"venue":          &collection.Venue,
+		"venueUkr":       &collection.VenueUkr,
+	} {
+		if v := req.FormValue(field); v != "" {
+			*value = v
+			update[field] = v
+		}
+	}
+
+	for field, value := range map[string]**primitive.DateTime{
+		"startDate": &collection.StartDate,
+		"endDate":   &collection.EndDate,
+	} {
+		dateStr := req.FormValue(field)
+		if dateStr == "" {
+			continue
+		}
+		date, err := time.Parse(time.RFC3339, dateStr)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s value: %v", field, err)
+		}
+		dt := primitive.NewDateTimeFromTime(date)
+		*value = &dt
+		update[field] = dt
+	}
+
+	if paintingsStr := req.FormValue("paintings"); paintingsStr != "" {
+		var hexes []string
+		err := json.Unmarshal([]byte(paintingsStr), &hexes)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid paintings value: %v", err)
+		}
+		ids := make([]primitive.ObjectID, 0, len(hexes))
+		for _, hex := range hexes {
+			id, err := primitive.ObjectIDFromHex(hex)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid paintings value: %q", hex)
+			}
+			ids = append(ids, id)
+		}
+		collection.PaintingIDs = ids
+		update["paintings"] = ids
+	}
+
+	return update, nil
+}
@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// JPEGFixture returns a small JPEG image to upload, for the tests of this
+// package and of controllers_test.
+func JPEGFixture(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for x := 0; x < 64; x++ {
+		img.Set(x, x%48, color.RGBA{R: 200, A: 255})
+	}
+	var b bytes.Buffer
+	err := jpeg.Encode(&b, img, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// FolderExists reports whether folder is in the local image store at root.
+func FolderExists(t *testing.T, root string, folder string) bool {
+	t.Helper()
+	_, err := os.Stat(filepath.Join(root, folder))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return err == nil
+}
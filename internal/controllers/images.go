@@ -0,0 +1,248 @@
+package controllers
+
+import (
+	"art/internal/models"
+	"art/internal/photoprocessor"
+	"art/internal/storage"
+	"context"
+	"errors"
+	"log"
+	"mime/multipart"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newFolderName returns a fresh image folder name, so that replacing images
+// never writes into the folder being replaced.
+func newFolderName(kind string) string {
+	return kind + "-" + primitive.NewObjectID().Hex()
+}
+
+// deleteFolder returns a step removing an image folder, for unitOfWork.
+func deleteFolder(store storage.ImageStore, folder string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if folder == "" {
+			return nil
+		}
+		return store.Delete(ctx, folder)
+	}
+}
+
+// deletePhotos returns a step removing the folders of photos, both the
+// published one and the one of their private originals, for unitOfWork.
+func deletePhotos(store storage.ImageStore, uploads Uploads, photos models.Photos) func(context.Context) error {
+	return func(ctx context.Context) error {
+		err := deleteFolder(store, photos.FolderId)(ctx)
+		if uploads.Originals != nil {
+			err = errors.Join(err, deleteFolder(uploads.Originals, photos.OriginalsFolder)(ctx))
+		}
+		return err
+	}
+}
+
+// replaceImage stores the image sent in the form field of req, if any, as the
+// photos of an entity of kind replacing old, like the portrait of an artist.
+// The new photos are deleted if work is rolled back, and old once it is
+// committed. ok is false if no image was sent.
+func replaceImage(req *http.Request, work *unitOfWork, store storage.ImageStore, uploads Uploads, field string, kind string, old models.Photos) (photos models.Photos, ok bool, err error) {
+	files := req.MultipartForm.File[field]
+	if len(files) == 0 {
+		return models.Photos{}, false, nil
+	}
+
+	photos, err = uploadPhotos(req.Context(), store, uploads, newFolderName(kind), files, uploads.watermark(nil))
+	if err != nil {
+		return models.Photos{}, false, err
+	}
+	work.onRollback(deletePhotos(store, uploads, photos))
+	work.onCommit(deletePhotos(store, uploads, old))
+	return photos, true, nil
+}
+
+// Uploads configures how uploaded images are processed and stored.
+type Uploads struct {
+	// Workers is how many files of one item are stored at once.
+	Workers int
+	// Renditions default to photoprocessor.DefaultRenditions.
+	Renditions []photoprocessor.Rendition
+	// Limits default to photoprocessor.DefaultLimits.
+	Limits *photoprocessor.Limits
+	// KeepMetadata names the EXIF fields published with each image, see
+	// photoprocessor.ParseMetadataFields. Other metadata is stripped.
+	KeepMetadata []string
+	// Watermark is overlaid on the renditions it names, unless turned off
+	// for a painting. Nil watermarks nothing.
+	Watermark *photoprocessor.Watermark
+	// Originals, if set, keeps the originals out of the published store, so
+	// that watermarks cannot be avoided by fetching them.
+	Originals storage.ImageStore
+}
+
+// watermark returns the watermark of images whose owner chose enabled, or
+// nil if they are not watermarked. A nil enabled follows the settings.
+func (u Uploads) watermark(enabled *bool) *photoprocessor.Watermark {
+	if enabled != nil && !*enabled {
+		return nil
+	}
+	return u.Watermark
+}
+
+// private reports whether the rendition r is kept in Originals.
+func (u Uploads) private(r photoprocessor.Rendition) bool {
+	return u.Originals != nil && r.LongSide == 0
+}
+
+func (u Uploads) limits() photoprocessor.Limits {
+	if u.Limits == nil {
+		return photoprocessor.DefaultLimits
+	}
+	return *u.Limits
+}
+
+func (u Uploads) renditions() []photoprocessor.Rendition {
+	if len(u.Renditions) == 0 {
+		return photoprocessor.DefaultRenditions
+	}
+	return u.Renditions
+}
+
+// primary returns the rendition listed in Photos.Urls: the largest resized
+// one, or the original if nothing is resized and it is published.
+func (u Uploads) primary() string {
+	var best photoprocessor.Rendition
+	for _, r := range u.renditions() {
+		if u.private(r) {
+			continue
+		}
+		if best.Name == "" || best.LongSide == 0 || r.LongSide > best.LongSide {
+			best = r
+		}
+	}
+	return best.Name
+}
+
+// uploadPhotos checks files against the upload limits, processes them into
+// every rendition in a workspace of its own, watermarked with watermark, and
+// stores them in folder with publishPhotos.
+func uploadPhotos(ctx context.Context, store storage.ImageStore, uploads Uploads, folder string, files []*multipart.FileHeader, watermark *photoprocessor.Watermark) (models.Photos, error) {
+	err := photoprocessor.CheckFiles(files, uploads.limits())
+	if err != nil {
+		return models.Photos{}, err
+	}
+
+	ws, err := photoprocessor.NewWorkspace("")
+	if err != nil {
+		return models.Photos{}, err
+	}
+	defer func() {
+		err := ws.Remove()
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+
+	processed, err := photoprocessor.SaveAndResizeFiles(&photoprocessor.LocalPhotoProcessor{}, files, ws, uploads.renditions(), watermark)
+	if err != nil {
+		return models.Photos{}, err
+	}
+	return publishPhotos(ctx, store, uploads, folder, processed)
+}
+
+// publishPhotos stores the processed photos in folder, and their private
+// originals in a folder of the same name in uploads.Originals.
+func publishPhotos(ctx context.Context, store storage.ImageStore, uploads Uploads, folder string, processed []photoprocessor.Photo) (models.Photos, error) {
+	renditions := uploads.renditions()
+	private := make(map[string]bool, len(renditions))
+	for _, r := range renditions {
+		private[r.Name] = uploads.private(r)
+	}
+	var paths, privatePaths []string
+	for _, p := range processed {
+		for _, f := range p.Files {
+			if private[f.Rendition] {
+				privatePaths = append(privatePaths, f.Path)
+			} else {
+				paths = append(paths, f.Path)
+			}
+		}
+	}
+	results, err := storeFiles(ctx, store, uploads.Workers, folder, paths)
+	if err != nil {
+		return models.Photos{}, err
+	}
+
+	var photos models.Photos
+	if len(privatePaths) > 0 {
+		privateResults, err := storeFiles(ctx, uploads.Originals, uploads.Workers, folder, privatePaths)
+		if err != nil {
+			if len(results) > 0 {
+				deleteErr := store.Delete(context.WithoutCancel(ctx), results[0].Object.Folder)
+				if deleteErr != nil {
+					log.Println(deleteErr)
+				}
+			}
+			return models.Photos{}, err
+		}
+		photos.OriginalsFolder = privateResults[0].Object.Folder
+	}
+
+	primary := uploads.primary()
+	for _, p := range processed {
+		image := models.Image{
+			Renditions: make(map[string]models.Rendition, len(p.Files)),
+			Metadata:   imageMetadata(p.Metadata.Keep(uploads.KeepMetadata)),
+		}
+		for _, f := range p.Files {
+			if private[f.Rendition] {
+				image.Renditions[f.Rendition] = models.Rendition{Width: f.Width, Height: f.Height, Private: true}
+				continue
+			}
+			r := results[0]
+			results = results[1:]
+			photos.FolderId = r.Object.Folder
+			image.Renditions[f.Rendition] = models.Rendition{URL: r.URL, Width: f.Width, Height: f.Height}
+			if f.Rendition == primary {
+				photos.Urls = append(photos.Urls, r.URL)
+			}
+		}
+		photos.Images = append(photos.Images, image)
+	}
+	return photos, nil
+}
+
+// imageMetadata returns the record of m, or nil if it is empty.
+func imageMetadata(m photoprocessor.Metadata) *models.ImageMetadata {
+	if m == (photoprocessor.Metadata{}) {
+		return nil
+	}
+	record := &models.ImageMetadata{Camera: m.Camera}
+	if !m.CapturedAt.IsZero() {
+		captured := primitive.NewDateTimeFromTime(m.CapturedAt)
+		record.CapturedAt = &captured
+	}
+	return record
+}
+
+// storeFiles stores the files at paths in folder. Files that fail are retried
+// once, resuming the upload, and if some still fail the images that made it
+// are removed rather than left in a half-uploaded folder.
+func storeFiles(ctx context.Context, store storage.ImageStore, workers int, folder string, paths []string) ([]storage.Result, error) {
+	results, err := storage.UploadFiles(ctx, store, folder, paths, workers)
+	var uploadErr *storage.UploadError
+	if !errors.As(err, &uploadErr) || ctx.Err() != nil {
+		return results, err
+	}
+
+	log.Printf("Resuming upload to %s: %v", folder, err)
+	results, err = storage.UploadFiles(ctx, store, folder, paths, workers)
+	if errors.As(err, &uploadErr) {
+		if uploaded, ok := uploadErr.Uploaded(); ok {
+			deleteErr := store.Delete(context.WithoutCancel(ctx), uploaded)
+			if deleteErr != nil {
+				log.Println(deleteErr)
+			}
+		}
+	}
+	return results, err
+}
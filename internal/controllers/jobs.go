@@ -0,0 +1,343 @@
+package controllers
+
+import (
+	"art/internal/models"
+	"art/internal/photoprocessor"
+	"art/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxJobAttempts is how many times a job is started before it is given up,
+// so that images crashing the server do not do so on every restart.
+const maxJobAttempts = 3
+
+// DefaultJobWorkers is how many photo jobs run at once by default.
+const DefaultJobWorkers = 2
+
+// PhotoJobs processes and stores the images of paintings in the background,
+// so that large uploads do not hold up the request sending them. Jobs are
+// kept in Jobs, and their files in Dir, so that the jobs a restart
+// interrupted are run again by Start.
+type PhotoJobs struct {
+	Jobs    *models.Jobs
+	Gallery *models.Gallery
+	Images  storage.ImageStore
+	Uploads Uploads
+	// Dir holds the workspaces of unfinished jobs. Unlike the temporary
+	// directory, it must survive a restart.
+	Dir string
+	// Workers is how many jobs run at once.
+	Workers int
+
+	ctx   context.Context
+	slots chan struct{}
+	wg    sync.WaitGroup
+}
+
+// errSuperseded fails jobs whose images were submitted before others for the
+// same painting.
+var errSuperseded = errors.New("Images submitted later replace these")
+
+// Start runs the unfinished jobs, and the jobs submitted from then on, until
+// ctx is done. Workspaces no unfinished job uses are removed.
+func (p *PhotoJobs) Start(ctx context.Context) error {
+	workers := p.Workers
+	if workers <= 0 {
+		workers = DefaultJobWorkers
+	}
+	p.ctx = ctx
+	p.slots = make(chan struct{}, workers)
+
+	jobs, err := p.Jobs.Unfinished(ctx)
+	if err != nil {
+		return err
+	}
+
+	var resumed []models.Job
+	for _, job := range jobs {
+		if job.Attempts >= maxJobAttempts {
+			p.fail(job, fmt.Errorf("gave up after %d attempts", job.Attempts), nil)
+			continue
+		}
+		resumed = append(resumed, job)
+	}
+
+	used := make(map[string]bool, len(resumed))
+	for _, job := range resumed {
+		used[job.Workspace] = true
+	}
+	workspaces, err := photoprocessor.ListWorkspaces(p.Dir)
+	if err != nil {
+		return err
+	}
+	for _, ws := range workspaces {
+		if !used[ws.Dir] {
+			err := ws.Remove()
+			if err != nil {
+				log.Println(err)
+			}
+		}
+	}
+
+	for _, job := range resumed {
+		p.enqueue(job.ID)
+	}
+	if len(resumed) > 0 {
+		log.Printf("Resumed %d photo jobs", len(resumed))
+	}
+	return nil
+}
+
+// Wait waits for the running jobs to stop once the context given to Start
+// is done. It must not be called before the requests that may submit jobs
+// were served.
+func (p *PhotoJobs) Wait() {
+	p.wg.Wait()
+}
+
+// Submit saves files, which must have been checked against the upload
+// limits, as a job storing them as the photos of the painting. The caller
+// sets the PhotosSeq of the painting to seq, as the job only replaces the
+// photos while it is. The job is queued once work is committed, and
+// cancelled if it is rolled back.
+func (p *PhotoJobs) Submit(ctx context.Context, work *unitOfWork, paintingID primitive.ObjectID, seq int64, files []*multipart.FileHeader, watermark bool) (models.Job, error) {
+	ws, err := photoprocessor.NewWorkspace(p.Dir)
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	job, err := p.add(ctx, ws, paintingID, seq, files, watermark)
+	if err != nil {
+		removeErr := ws.Remove()
+		if removeErr != nil {
+			log.Println(removeErr)
+		}
+		return models.Job{}, err
+	}
+
+	work.onRollback(func(ctx context.Context) error {
+		return errors.Join(p.Jobs.Fail(ctx, job.ID, errors.New("Cancelled"), nil), ws.Remove())
+	})
+	work.onCommit(func(ctx context.Context) error {
+		p.enqueue(job.ID)
+		return nil
+	})
+	return job, nil
+}
+
+func (p *PhotoJobs) add(ctx context.Context, ws *photoprocessor.Workspace, paintingID primitive.ObjectID, seq int64, files []*multipart.FileHeader, watermark bool) (models.Job, error) {
+	processor := &photoprocessor.LocalPhotoProcessor{}
+	err := processor.SavePhotos(files, ws.Originals())
+	if err != nil {
+		return models.Job{}, err
+	}
+
+	return p.Jobs.Add(ctx, models.Job{
+		PaintingID: paintingID,
+		Progress:   models.JobProgress{Total: len(files)},
+		Workspace:  ws.Dir,
+		Folder:     newFolderName("painting"),
+		Watermark:  watermark,
+		PhotosSeq:  seq,
+	})
+}
+
+// enqueue runs the job once a worker is free. Jobs submitted once the
+// context given to Start is done are left queued, to be resumed on the next
+// start, so that Wait does not race with requests still being served.
+func (p *PhotoJobs) enqueue(id primitive.ObjectID) {
+	if p.ctx.Err() != nil {
+		return
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		select {
+		case p.slots <- struct{}{}:
+		case <-p.ctx.Done():
+			return
+		}
+		defer func() { <-p.slots }()
+
+		p.run(p.ctx, id)
+	}()
+}
+
+func (p *PhotoJobs) run(ctx context.Context, id primitive.ObjectID) {
+	job, err := p.Jobs.One(ctx, id)
+	if err != nil {
+		log.Printf("Could not load job %s: %v", id.Hex(), err)
+		return
+	}
+	if job.Finished() {
+		return
+	}
+	err = p.Jobs.Start(ctx, id)
+	if err != nil {
+		log.Printf("Could not start job %s: %v", id.Hex(), err)
+		return
+	}
+
+	photos, err := p.process(ctx, job)
+	if err == nil {
+		err = p.replacePhotos(ctx, job, photos)
+		if err != nil {
+			deleteErr := deletePhotos(p.Images, p.Uploads, photos)(context.WithoutCancel(ctx))
+			if deleteErr != nil {
+				log.Println(deleteErr)
+			}
+		}
+	}
+
+	// A job interrupted by a shutdown is left for the next start, with its
+	// workspace and the images it already stored.
+	if err != nil && ctx.Err() != nil {
+		err = p.Jobs.Requeue(context.WithoutCancel(ctx), id)
+		if err != nil {
+			log.Printf("Could not requeue job %s: %v", id.Hex(), err)
+		}
+		return
+	}
+
+	if err != nil {
+		p.fail(job, err, failures(err))
+	} else {
+		err = p.Jobs.Finish(ctx, id)
+		if err != nil {
+			log.Printf("Could not finish job %s: %v", id.Hex(), err)
+		}
+	}
+	err = (&photoprocessor.Workspace{Dir: job.Workspace}).Remove()
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// process resizes the files of job and stores them, reporting its progress.
+func (p *PhotoJobs) process(ctx context.Context, job models.Job) (models.Photos, error) {
+	progress := func(stage string, done int, total int) {
+		err := p.Jobs.Progress(ctx, job.ID, models.JobProgress{Stage: stage, Done: done, Total: total})
+		if err != nil {
+			log.Printf("Could not update job %s: %v", job.ID.Hex(), err)
+		}
+	}
+
+	var watermark *photoprocessor.Watermark
+	if job.Watermark {
+		watermark = p.Uploads.Watermark
+	}
+	ws := &photoprocessor.Workspace{Dir: job.Workspace}
+	processed, err := photoprocessor.ResizeFiles(&photoprocessor.LocalPhotoProcessor{}, ws, p.Uploads.renditions(), watermark, func(done int, total int) {
+		progress(models.StageProcessing, done, total)
+	})
+	if err != nil {
+		return models.Photos{}, err
+	}
+
+	progress(models.StageUploading, 0, len(processed))
+	photos, err := publishPhotos(ctx, p.Images, p.Uploads, job.Folder, processed)
+	if err != nil {
+		return models.Photos{}, err
+	}
+	progress(models.StageUploading, len(processed), len(processed))
+	return photos, nil
+}
+
+// replacePhotos sets the photos of the painting of job, unless images were
+// submitted for it after those of job, and deletes the ones they replace.
+func (p *PhotoJobs) replacePhotos(ctx context.Context, job models.Job, photos models.Photos) error {
+	painting, err := p.Gallery.GetOnePainting(ctx, job.PaintingID)
+	if err != nil {
+		return err
+	}
+	if painting.PhotosSeq != job.PhotosSeq {
+		return errSuperseded
+	}
+	// Only the job of the latest submission gets past the condition, so the
+	// photos read above are still the ones replaced.
+	err = p.Gallery.UpdatePaintingIf(ctx, job.PaintingID, photosSeqIs(job.PhotosSeq), bson.M{"$set": bson.M{"photos": photos}})
+	if errors.Is(err, models.ErrConflict) {
+		return errSuperseded
+	}
+	if err != nil {
+		return err
+	}
+
+	// A job run again after it replaced the photos finds its own.
+	if painting.Photos.FolderId != photos.FolderId {
+		err = deletePhotos(p.Images, p.Uploads, painting.Photos)(ctx)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+	return nil
+}
+
+// photosSeqIs matches paintings whose latest images were submitted as seq.
+func photosSeqIs(seq int64) bson.M {
+	if seq == 0 {
+		return bson.M{"photosSeq": nil}
+	}
+	return bson.M{"photosSeq": seq}
+}
+
+func (p *PhotoJobs) fail(job models.Job, err error, failures []models.JobFailure) {
+	log.Printf("Photo job %s for painting %s failed: %v", job.ID.Hex(), job.PaintingID.Hex(), err)
+	err = p.Jobs.Fail(context.WithoutCancel(p.ctx), job.ID, err, failures)
+	if err != nil {
+		log.Printf("Could not fail job %s: %v", job.ID.Hex(), err)
+	}
+}
+
+// failures lists the images err reports could not be stored.
+func failures(err error) []models.JobFailure {
+	var uploadErr *storage.UploadError
+	if !errors.As(err, &uploadErr) {
+		return nil
+	}
+	var failed []models.JobFailure
+	for _, r := range uploadErr.Results {
+		if r.Err != nil {
+			failed = append(failed, models.JobFailure{Name: r.Name, Error: r.Err.Error()})
+		}
+	}
+	return failed
+}
+
+// accepted is the response data of a request whose images are stored by a
+// job, followed with GET /jobs/{id}.
+type accepted struct {
+	ID  string `json:"id,omitempty"`
+	Job string `json:"job,omitempty"`
+}
+
+type JobController struct {
+	Jobs *models.Jobs
+}
+
+func (c *JobController) GetJob(res http.ResponseWriter, req *http.Request) {
+	params := mux.Vars(req)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	job, err := c.Jobs.One(req.Context(), id)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, Response{Data: job})
+}
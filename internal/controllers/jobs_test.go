@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"art/internal/db"
+	"art/internal/models"
+	"art/internal/photoprocessor"
+	"art/internal/storage"
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newTestJobs runs photo jobs from memory state, storing images under the
+// returned directory.
+func newTestJobs(t *testing.T) (*PhotoJobs, string) {
+	t.Helper()
+	images, err := storage.NewLocalStore(t.TempDir(), "/images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &PhotoJobs{
+		Jobs:    models.NewJobs(db.NewMemoryJobState()),
+		Gallery: models.NewGallery(db.NewMemoryGalleryState()),
+		Images:  images,
+		Dir:     t.TempDir(),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		p.Wait()
+	})
+	err = p.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, images.Root
+}
+
+// storedPhotos stores an image in a new folder of the images of p.
+func storedPhotos(t *testing.T, p *PhotoJobs) models.Photos {
+	t.Helper()
+	folder := newFolderName("painting")
+	obj, err := p.Images.Put(context.Background(), folder, "0-large.jpg", bytes.NewReader([]byte("large")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return models.Photos{FolderId: obj.Folder}
+}
+
+// jpegFiles returns the file headers of a form sending one JPEG image.
+func jpegFiles(t *testing.T) []*multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("images", "painting.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = part.Write(JPEGFixture(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form, err := multipart.NewReader(&body, w.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	return form.File["images"]
+}
+
+func TestReplacePhotosKeepsLatestSubmission(t *testing.T) {
+	ctx := context.Background()
+	p, root := newTestJobs(t)
+
+	old := storedPhotos(t, p)
+	id, err := p.Gallery.AddProduct(ctx, models.Painting{Title: "Dawn", Photos: old, PhotosSeq: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The images submitted first are processed last: they are discarded.
+	latest := storedPhotos(t, p)
+	err = p.replacePhotos(ctx, models.Job{PaintingID: id, PhotosSeq: 2}, latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := storedPhotos(t, p)
+	err = p.replacePhotos(ctx, models.Job{PaintingID: id, PhotosSeq: 1}, stale)
+	if !errors.Is(err, errSuperseded) {
+		t.Fatalf("stale job got %v, want %v", err, errSuperseded)
+	}
+
+	painting, err := p.Gallery.GetOnePainting(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if painting.Photos.FolderId != latest.FolderId {
+		t.Errorf("painting has photos %s, want %s", painting.Photos.FolderId, latest.FolderId)
+	}
+	if FolderExists(t, root, old.FolderId) {
+		t.Error("replaced photos were not deleted")
+	}
+	if !FolderExists(t, root, latest.FolderId) {
+		t.Error("latest photos were deleted")
+	}
+}
+
+func TestReplacePhotosWithoutSeq(t *testing.T) {
+	ctx := context.Background()
+	p, _ := newTestJobs(t)
+
+	// Paintings stored before submissions were counted have no PhotosSeq,
+	// like the jobs queued for them then.
+	id, err := p.Gallery.AddProduct(ctx, models.Painting{Title: "Dusk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	photos := storedPhotos(t, p)
+	err = p.replacePhotos(ctx, models.Job{PaintingID: id}, photos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	painting, err := p.Gallery.GetOnePainting(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if painting.Photos.FolderId != photos.FolderId {
+		t.Errorf("painting has photos %q, want %s", painting.Photos.FolderId, photos.FolderId)
+	}
+}
+
+func TestSubmitRunsOnlyOnCommit(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("rolled back", func(t *testing.T) {
+		p, _ := newTestJobs(t)
+		id, err := p.Gallery.AddProduct(ctx, models.Painting{Title: "Dawn", PhotosSeq: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var work unitOfWork
+		job, err := p.Submit(ctx, &work, id, 1, jpegFiles(t), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		work.rollback(ctx)
+		p.Wait()
+
+		job, err = p.Jobs.One(ctx, job.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != models.JobFailed || job.Error != "Cancelled" {
+			t.Errorf("job is %s (%s), want cancelled", job.Status, job.Error)
+		}
+		workspaces, err := photoprocessor.ListWorkspaces(p.Dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(workspaces) != 0 {
+			t.Errorf("%d workspaces left, want none", len(workspaces))
+		}
+	})
+
+	t.Run("committed", func(t *testing.T) {
+		p, root := newTestJobs(t)
+		id, err := p.Gallery.AddProduct(ctx, models.Painting{Title: "Dawn", PhotosSeq: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var work unitOfWork
+		job, err := p.Submit(ctx, &work, id, 1, jpegFiles(t), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		work.commit(ctx)
+		p.Wait()
+
+		job, err = p.Jobs.One(ctx, job.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != models.JobDone {
+			t.Fatalf("job is %s (%s), want done", job.Status, job.Error)
+		}
+		painting, err := p.Gallery.GetOnePainting(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if painting.Photos.FolderId != job.Folder || !FolderExists(t, root, job.Folder) {
+			t.Errorf("painting has photos %q, want the stored folder %s", painting.Photos.FolderId, job.Folder)
+		}
+	})
+}
+
+func TestSubmitAfterShutdownStaysQueued(t *testing.T) {
+	p, _ := newTestJobs(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	err := p.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	id, err := p.Gallery.AddProduct(context.Background(), models.Painting{Title: "Dawn", PhotosSeq: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var work unitOfWork
+	job, err := p.Submit(context.Background(), &work, id, 1, jpegFiles(t), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	work.commit(context.Background())
+	p.Wait()
+
+	job, err = p.Jobs.One(context.Background(), job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != models.JobQueued {
+		t.Errorf("job is %s, want queued for the next start", job.Status)
+	}
+}
+
+func TestStartKeepsPendingWorkspaces(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	jobs := models.NewJobs(db.NewMemoryJobState())
+
+	// A job queued long ago, whose workspace is old enough to be swept,
+	// and the workspace of an upload that never became a job.
+	pending, err := photoprocessor.NewWorkspace(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = jobs.Add(ctx, models.Job{PaintingID: primitive.NewObjectID(), Workspace: pending.Dir, Folder: newFolderName("painting")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orphan, err := photoprocessor.NewWorkspace(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	for _, ws := range []string{pending.Dir, orphan.Dir} {
+		err = os.Chtimes(ws, old, old)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Start is stopped before the job runs: only the workspaces are checked.
+	p := &PhotoJobs{Jobs: jobs, Gallery: models.NewGallery(db.NewMemoryGalleryState()), Dir: dir}
+	startCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	err = p.Start(startCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Wait()
+
+	if _, err := os.Stat(pending.Dir); err != nil {
+		t.Errorf("workspace of the queued job was removed: %v", err)
+	}
+	if _, err := os.Stat(orphan.Dir); !os.IsNotExist(err) {
+		t.Errorf("workspace no job uses was kept: %v", err)
+	}
+}
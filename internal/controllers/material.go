@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"art/internal/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type MaterialController struct {
+	Materials *models.Materials
+}
+
+func (w *MaterialController) ListMaterials(res http.ResponseWriter, req *http.Request) {
+	materials, err := w.Materials.List(req.Context())
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, Response{Data: materials})
+}
+
+func (w *MaterialController) GetOneMaterial(res http.ResponseWriter, req *http.Request) {
+	material, err := w.Materials.One(req.Context(), mux.Vars(req)["id"])
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, Response{Data: material})
+}
+
+func (w *MaterialController) AddMaterial(res http.ResponseWriter, req *http.Request) {
+	var material models.Material
+	err := json.NewDecoder(req.Body).Decode(&material)
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: fmt.Sprintf("Invalid material: %v", err)})
+		return
+	}
+	err = material.Validate()
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	err = w.Materials.Add(req.Context(), material)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusCreated, Response{Data: material, Message: "Material created successfully"})
+}
+
+func (w *MaterialController) UpdateMaterial(res http.ResponseWriter, req *http.Request) {
+	var material models.Material
+	err := json.NewDecoder(req.Body).Decode(&material)
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: fmt.Sprintf("Invalid material: %v", err)})
+		return
+	}
+	material.ID = mux.Vars(req)["id"]
+	err = material.Validate()
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	err = w.Materials.Update(req.Context(), material)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, Response{Data: material, Message: "Material updated successfully"})
+}
+
+func (w *MaterialController) DeleteMaterial(res http.ResponseWriter, req *http.Request) {
+	err := w.Materials.Delete(req.Context(), mux.Vars(req)["id"])
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, Response{Message: "Material deleted successfully"})
+}
@@ -1,50 +1,143 @@
 package controllers
 
 import (
-	"art/internal/drive"
 	models "art/internal/models"
 	"art/internal/photoprocessor"
+	"art/internal/storage"
+	"art/internal/types"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"io"
 	"log"
 	"net/http"
 	"strconv"
-	"time"
 )
 
 type GalleryController struct {
-	Gallery *models.Gallery
+	Gallery     *models.Gallery
+	Users       *models.Users
+	Materials   *models.Materials
+	Artists     *models.Artists
+	Collections *models.Collections
+	Images      storage.ImageStore
+	Uploads     Uploads
+	PhotoJobs   *PhotoJobs
 }
 
-type Response struct {
-	Data    interface{} `json:"data,omitempty"`
-	Message string      `json:"message,omitempty"`
-	Error   string      `json:"error,omitempty"`
+type availabilityTransition struct {
+	State    string `json:"state"`
+	Note     string `json:"note"`
+	Override bool   `json:"override"`
 }
 
 func (w *GalleryController) ListProducts(res http.ResponseWriter, req *http.Request) {
-	products := w.Gallery.ListProducts()
+	q, err := parsePaintingQuery(req)
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+	unit, err := parseUnit(req)
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
 
-	res.Header().Set("Content-Type", "application/json")
-	err := json.NewEncoder(res).Encode(products)
+	q.Filter, err = w.Collections.Scope(req.Context(), q.Filter)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	products, total, err := w.Gallery.ListProducts(req.Context(), q)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	for i := range products {
+		convertSize(&products[i], unit)
+	}
+
+	writeJSON(res, http.StatusOK, Response{Data: products, Meta: newPageMeta(req, q, total)})
+}
+
+func (w *GalleryController) ListFacets(res http.ResponseWriter, req *http.Request) {
+	filter, err := parsePaintingFilter(req.URL.Query())
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	filter, err = w.Collections.Scope(req.Context(), filter)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	facets, err := w.Gallery.Facets(req.Context(), filter)
 	if err != nil {
+		writeError(res, err)
 		return
 	}
+
+	writeJSON(res, http.StatusOK, Response{Data: facets})
+}
+
+func (w *GalleryController) SearchPaintings(res http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query().Get("q")
+	if query == "" {
+		writeJSON(res, http.StatusBadRequest, Response{Error: "Query parameter q must be present"})
+		return
+	}
+
+	unit, err := parseUnit(req)
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	limit := defaultPerPage
+	if limitStr := req.URL.Query().Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxPerPage {
+			writeJSON(res, http.StatusBadRequest, Response{Error: fmt.Sprintf("Invalid limit value: %q, must be between 1 and %d", limitStr, maxPerPage)})
+			return
+		}
+	}
+
+	results, err := w.Gallery.Search(req.Context(), query, limit)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+	for i := range results {
+		convertSize(&results[i].Painting, unit)
+	}
+
+	writeJSON(res, http.StatusOK, Response{Data: results})
 }
 
 func (w *GalleryController) GetOnePainting(res http.ResponseWriter, req *http.Request) {
 	params := mux.Vars(req)
 	id, err := primitive.ObjectIDFromHex(params["id"])
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusBadRequest)
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+	unit, err := parseUnit(req)
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+	painting, err := w.Gallery.GetOnePainting(req.Context(), id)
+	if err != nil {
+		writeError(res, err)
 		return
 	}
-	painting := w.Gallery.GetOnePainting(id)
+	convertSize(&painting, unit)
 	res.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(res).Encode(painting)
 }
@@ -52,133 +145,176 @@ func (w *GalleryController) GetOnePainting(res http.ResponseWriter, req *http.Re
 func (w *GalleryController) AddPainting(res http.ResponseWriter, req *http.Request) {
 	err := req.ParseMultipartForm(10 << 20)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
 		return
 	}
 
-	files := req.MultipartForm.File["images"]
-	processor := &photoprocessor.LocalPhotoProcessor{}
-	err = photoprocessor.SaveAndResizeFiles(processor, files, "tmp/tmpFiles", "tmp/resizedFiles")
+	validation := NewValidation(req, w.Materials, w.Artists)
+	painting, err := validation.Validate("price", "date", "materials", "size", "title", "titleUkr", "description", "descriptionUkr", "availability", "artist", "watermark")
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
 		return
 	}
+	files := req.MultipartForm.File["images"]
+	if len(files) > 0 {
+		err = photoprocessor.CheckFiles(files, w.Uploads.limits())
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+	}
+	painting.ID = primitive.NewObjectID()
+	if len(files) > 0 {
+		painting.PhotosSeq = 1
+	}
 
-	validation := NewValidation(req)
-	painting, err := validation.Validate("price", "date", "materials", "size", "title", "titleUkr", "description", "descriptionUkr", "availability")
+	var work unitOfWork
+	defer work.rollback(req.Context())
 
-	painting.Photos, err = drive.UploadImages(req.FormValue("title"), "tmp/resizedFiles")
+	// The ID is known up front so that an insert that fails after all, e.g.
+	// by timing out, can still be undone.
+	work.onRollback(func(ctx context.Context) error {
+		err := w.Gallery.DeletePainting(ctx, painting.ID)
+		if errors.Is(err, models.ErrNotFound) {
+			return nil
+		}
+		return err
+	})
+	id, err := w.Gallery.AddProduct(req.Context(), painting)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		writeError(res, err)
 		return
 	}
 
-	id := w.Gallery.AddProduct(painting)
-
-	fmt.Printf("Inserted painting with ID: %s\n", id.Hex())
-
-	defer func() {
-		err = processor.RemoveFolder("tmp/tmpFiles")
-		if err != nil {
-			log.Println(err)
-		}
-		err = processor.RemoveFolder("tmp/resizedFiles")
+	// The images are stored by a job, which fills in the photos of the
+	// painting once it is done. Without images the data is the ID alone, as
+	// for every other entity created.
+	var created interface{} = id.Hex()
+	status := http.StatusOK
+	if len(files) > 0 {
+		job, err := w.PhotoJobs.Submit(req.Context(), &work, id, painting.PhotosSeq, files, w.Uploads.watermark(painting.Watermark) != nil)
 		if err != nil {
-			log.Println(err)
+			writeError(res, err)
+			return
 		}
-	}()
-
-	response := Response{
-		Data:    id.Hex(),
-		Message: "Painting created successfully",
+		created = accepted{ID: id.Hex(), Job: job.ID.Hex()}
+		status = http.StatusAccepted
 	}
+	work.commit(req.Context())
 
-	res.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(res).Encode(response)
-	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	log.Printf("Inserted painting with ID: %s", id.Hex())
+	writeJSON(res, status, Response{Data: created, Message: "Painting created successfully"})
 }
 
 func (w *GalleryController) DeletePainting(res http.ResponseWriter, req *http.Request) {
-	_, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
 	params := mux.Vars(req)
 	id, err := primitive.ObjectIDFromHex(params["id"])
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusBadRequest)
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
 		return
 	}
 
-	painting := w.Gallery.GetOnePainting(id)
-	err = drive.DeleteFolder(painting.Photos.FolderId)
+	painting, err := w.Gallery.GetOnePainting(req.Context(), id)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		writeError(res, err)
 		return
 	}
 
-	if !w.Gallery.DeletePainting(id) {
-		http.Error(res, "Painting not found", http.StatusNotFound)
+	err = w.Gallery.DeletePainting(req.Context(), id)
+	if err != nil {
+		writeError(res, err)
 		return
 	}
 
-	fmt.Printf("Deleted painting with ID: %s\n", id.Hex())
-	res.Header().Set("Content-Type", "application/json")
-	_, _ = io.WriteString(res, "Painting deleted successfully")
+	// The images are only deleted once nothing points at them.
+	err = deletePhotos(w.Images, w.Uploads, painting.Photos)(req.Context())
+	if err != nil {
+		log.Println(err)
+	}
+
+	log.Printf("Deleted painting with ID: %s", id.Hex())
+	writeJSON(res, http.StatusOK, Response{Message: "Painting deleted successfully"})
 }
 
 func (w *GalleryController) UpdatePainting(res http.ResponseWriter, req *http.Request) {
-	err := req.ParseMultipartForm(10 << 20) //
+	err := req.ParseMultipartForm(10 << 20)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
 		return
 	}
 
 	files := req.MultipartForm.File["images"]
+	if len(files) > 0 {
+		err = photoprocessor.CheckFiles(files, w.Uploads.limits())
+		if err != nil {
+			writeError(res, err)
+			return
+		}
+	}
 
 	params := mux.Vars(req)
 	id, err := primitive.ObjectIDFromHex(params["id"])
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusBadRequest)
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	painting, err := w.Gallery.GetOnePainting(req.Context(), id)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	if req.FormValue("availability") != "" {
+		writeJSON(res, http.StatusBadRequest, Response{Error: "Availability can only be changed through POST /paintings/{id}/availability"})
 		return
 	}
+
 	update := bson.M{}
-	// If there are new images, delete the old folder from Google Drive
 
-	if len(files) > 0 {
-		files := req.MultipartForm.File["images"]
-		processor := &photoprocessor.LocalPhotoProcessor{}
-		err = photoprocessor.SaveAndResizeFiles(processor, files, "tmp/tmpFiles", "tmp/resizedFiles")
+	if sizeStr := req.FormValue("size"); sizeStr != "" {
+		size, err := parseDimensions(sizeStr)
 		if err != nil {
-			http.Error(res, err.Error(), http.StatusInternalServerError)
+			writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
 			return
 		}
+		update["size"] = size
+	}
 
-		err = drive.DeleteFolder(id.Hex())
+	if materialsStr := req.FormValue("materials"); materialsStr != "" {
+		ids, err := parseMaterialIDs(materialsStr)
+		if err != nil {
+			writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+			return
+		}
+		materials, err := w.Materials.Resolve(req.Context(), ids)
 		if err != nil {
-			http.Error(res, err.Error(), http.StatusInternalServerError)
+			writeError(res, err)
 			return
 		}
+		update["materials"] = materials
+	}
 
-		imageLinks, err := drive.UploadImages(id.Hex(), "tmp/tmpFiles")
+	if artistStr := req.FormValue("artist"); artistStr != "" {
+		artistID, err := w.Artists.Resolve(req.Context(), artistStr)
 		if err != nil {
-			http.Error(res, err.Error(), http.StatusInternalServerError)
+			writeError(res, err)
 			return
 		}
-		update["photos"] = imageLinks
-		defer func() {
-			err = processor.RemoveFolder("tmp/tmpFiles")
-			if err != nil {
-				log.Println(err)
-			}
-			err = processor.RemoveFolder("tmp/resizedFiles")
-			if err != nil {
-				log.Println(err)
-			}
-		}()
+		update["artistId"] = artistID
+	}
 
+	// Changing the watermark setting only applies to images uploaded from
+	// then on; the stored ones are not processed again.
+	watermark := painting.Watermark
+	if watermarkStr := req.FormValue("watermark"); watermarkStr != "" {
+		enabled, err := parseWatermark(watermarkStr)
+		if err != nil {
+			writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+			return
+		}
+		watermark = &enabled
+		update["watermark"] = enabled
 	}
 
 	if req.FormValue("title") != "" {
@@ -193,41 +329,119 @@ func (w *GalleryController) UpdatePainting(res http.ResponseWriter, req *http.Re
 	if req.FormValue("descriptionUkr") != "" {
 		update["descriptionUkr"] = req.FormValue("descriptionUkr")
 	}
-	if priceStr := req.FormValue("price"); priceStr != "" {
-		price, err := strconv.ParseFloat(priceStr, 64)
-		if err == nil {
-			update["price"] = price
-		}
+	// Price and date are checked as when the painting was added, rather than
+	// dropped when invalid.
+	validation := NewValidation(req, w.Materials, w.Artists)
+	var fields []string
+	if req.FormValue("price") != "" {
+		fields = append(fields, "price")
 	}
-	if dateStr := req.FormValue("date"); dateStr != "" {
-		date, err := time.Parse(time.RFC3339, dateStr)
-		if err == nil {
-			update["date"] = primitive.NewDateTimeFromTime(date)
-		}
+	if req.FormValue("date") != "" {
+		fields = append(fields, "date")
 	}
-	if materialsStr := req.FormValue("materials"); materialsStr != "" {
-		var materials []models.Material
-		err := json.Unmarshal([]byte(materialsStr), &materials)
-		if err == nil {
-			update["materials"] = materials
+	validated, err := validation.Validate(fields...)
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+	if req.FormValue("price") != "" {
+		update["price"] = validated.Price
+	}
+	if req.FormValue("date") != "" {
+		update["date"] = validated.Date
+	}
+
+	var work unitOfWork
+	defer work.rollback(req.Context())
+
+	// New images are stored by a job, which replaces the photos of the
+	// painting once it is done, unless others were submitted meanwhile. The
+	// fields are only updated if nobody submitted images since the painting
+	// was read, so that every submission gets a sequence number of its own.
+	var job models.Job
+	cond := bson.M{}
+	if len(files) > 0 {
+		seq := painting.PhotosSeq + 1
+		job, err = w.PhotoJobs.Submit(req.Context(), &work, id, seq, files, w.Uploads.watermark(watermark) != nil)
+		if err != nil {
+			writeError(res, err)
+			return
 		}
+		update["photosSeq"] = seq
+		cond = photosSeqIs(painting.PhotosSeq)
 	}
-	if sizeStr := req.FormValue("size"); sizeStr != "" {
-		var size []interface{}
-		err := json.Unmarshal([]byte(sizeStr), &size)
-		if err == nil {
-			update["size"] = size
+
+	if len(update) > 0 {
+		err = w.Gallery.UpdatePaintingIf(req.Context(), id, cond, bson.M{"$set": update})
+		if err != nil {
+			writeError(res, err)
+			return
 		}
 	}
-	if req.FormValue("availability") != "" {
-		update["availability"] = req.FormValue("availability")
+	work.commit(req.Context())
+
+	if len(files) > 0 {
+		writeJSON(res, http.StatusAccepted, Response{Data: accepted{Job: job.ID.Hex()}, Message: "Painting updated, its images are being processed"})
+		return
 	}
+	writeJSON(res, http.StatusOK, Response{Message: "Painting updated successfully"})
+}
 
-	if len(update) > 0 {
-		if !w.Gallery.UpdatePainting(id, bson.M{"$set": update}) {
-			http.Error(res, "Failed to update painting", http.StatusInternalServerError)
+func (w *GalleryController) TransitionAvailability(res http.ResponseWriter, req *http.Request) {
+	params := mux.Vars(req)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	var transition availabilityTransition
+	err = json.NewDecoder(req.Body).Decode(&transition)
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: fmt.Sprintf("Invalid transition: %v", err)})
+		return
+	}
+
+	login, _ := req.Context().Value(types.CONTEXT_LOGIN_KEY).(types.Login)
+	if transition.Override {
+		admin, err := w.Users.IsAdmin(req.Context(), login)
+		if err != nil && !errors.Is(err, models.ErrNotFound) {
+			writeError(res, err)
 			return
 		}
+		if !admin {
+			writeJSON(res, http.StatusForbidden, Response{Error: "Only admins can override availability transitions"})
+			return
+		}
+	}
+
+	change, err := w.Gallery.SetAvailability(req.Context(), id, models.AvailabilityChange{
+		To:       transition.State,
+		Actor:    string(login),
+		Note:     transition.Note,
+		Override: transition.Override,
+	})
+	if err != nil {
+		writeError(res, err)
+		return
 	}
-	_, _ = io.WriteString(res, "Painting updated successfully")
+
+	writeJSON(res, http.StatusOK, Response{Data: change, Message: "Availability updated successfully"})
+}
+
+func (w *GalleryController) AvailabilityHistory(res http.ResponseWriter, req *http.Request) {
+	params := mux.Vars(req)
+	id, err := primitive.ObjectIDFromHex(params["id"])
+	if err != nil {
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+		return
+	}
+
+	history, err := w.Gallery.AvailabilityHistory(req.Context(), id)
+	if err != nil {
+		writeError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, Response{Data: history})
 }
@@ -0,0 +1,337 @@
+package controllers_test
+
+import (
+	"art/internal/api"
+	"art/internal/controllers"
+	"art/internal/db"
+	"art/internal/models"
+	"art/internal/storage"
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testServer serves the API from memory state, with no database, and keeps
+// images under Images.
+type testServer struct {
+	*httptest.Server
+	Images string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	images, err := storage.NewLocalStore(t.TempDir(), "/images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gallery := db.NewMemoryGalleryState()
+	gl := models.NewGallery(gallery)
+	us := models.NewUsers(db.NewMemoryUserState())
+	ms := models.NewMaterials(db.NewMemoryMaterialState(), gallery)
+	as := models.NewArtists(db.NewMemoryArtistState(), gallery)
+	cs := models.NewCollections(db.NewMemoryCollectionState(), gallery)
+	js := models.NewJobs(db.NewMemoryJobState())
+
+	photoJobs := &controllers.PhotoJobs{Jobs: js, Gallery: gl, Images: images, Dir: t.TempDir()}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		photoJobs.Wait()
+	})
+	err = photoJobs.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	glc := &controllers.GalleryController{Gallery: gl, Users: us, Materials: ms, Artists: as, Collections: cs, Images: images, PhotoJobs: photoJobs}
+	usc := &controllers.UserControllers{Users: us}
+	mc := &controllers.MaterialController{Materials: ms}
+	ac := &controllers.ArtistController{Artists: as, Collections: cs, Images: images}
+	cc := &controllers.CollectionController{Collections: cs, Images: images}
+	jc := &controllers.JobController{Jobs: js}
+
+	server := httptest.NewServer(api.NewRouter(glc, usc, mc, ac, cc, jc))
+	t.Cleanup(server.Close)
+	return &testServer{Server: server, Images: images.Root}
+}
+
+func multipartBody(t *testing.T, fields map[string]string) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for name, value := range fields {
+		err := w.WriteField(name, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &body, w.FormDataContentType()
+}
+
+// do sends a request and decodes the response envelope into data.
+func do(t *testing.T, req *http.Request, data interface{}) (int, controllers.Response) {
+	t.Helper()
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if got := res.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("%s %s: Content-Type %q, want application/json", req.Method, req.URL.Path, got)
+	}
+	var envelope struct {
+		controllers.Response
+		Data json.RawMessage `json:"data"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&envelope)
+	if err != nil {
+		t.Fatalf("%s %s: %v", req.Method, req.URL.Path, err)
+	}
+	if data != nil && len(envelope.Data) > 0 {
+		err = json.Unmarshal(envelope.Data, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return res.StatusCode, envelope.Response
+}
+
+func newRequest(t *testing.T, method string, url string, fields map[string]string) *http.Request {
+	t.Helper()
+	var req *http.Request
+	var err error
+	if fields == nil {
+		req, err = http.NewRequest(method, url, nil)
+	} else {
+		body, contentType := multipartBody(t, fields)
+		req, err = http.NewRequest(method, url, body)
+		if err == nil {
+			req.Header.Set("Content-Type", contentType)
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestPaintingLifecycle(t *testing.T) {
+	server := newTestServer(t)
+
+	var id string
+	status, res := do(t, newRequest(t, "POST", server.URL+"/paintings/add", map[string]string{
+		"title":     "Sunflowers",
+		"price":     "1200",
+		"date":      "1888-08-01T00:00:00Z",
+		"materials": "[]",
+		"size":      `{"width": 73, "height": 92}`,
+	}), &id)
+	if status != http.StatusOK || id == "" {
+		t.Fatalf("create: %d %+v", status, res)
+	}
+
+	var painting models.Painting
+	getPainting := func() int {
+		res, err := http.Get(server.URL + "/paintings/" + id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		painting = models.Painting{}
+		if res.StatusCode == http.StatusOK {
+			err = json.NewDecoder(res.Body).Decode(&painting)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return res.StatusCode
+	}
+	if status := getPainting(); status != http.StatusOK || painting.Title != "Sunflowers" || painting.Availability != models.AvailabilityAvailable {
+		t.Fatalf("get: %d %+v", status, painting)
+	}
+
+	status, res = do(t, newRequest(t, "PUT", server.URL+"/paintings/"+id, map[string]string{"title": "Irises", "price": "1500"}), nil)
+	if status != http.StatusOK {
+		t.Fatalf("update: %d %+v", status, res)
+	}
+	if getPainting(); painting.Title != "Irises" || painting.Price != 1500 {
+		t.Errorf("after update: %+v", painting)
+	}
+
+	var listed []models.Painting
+	status, res = do(t, newRequest(t, "GET", server.URL+"/paintings", nil), &listed)
+	if status != http.StatusOK || len(listed) != 1 || res.Meta == nil || res.Meta.Total != 1 {
+		t.Errorf("list: %d %+v %d paintings", status, res, len(listed))
+	}
+
+	status, res = do(t, newRequest(t, "DELETE", server.URL+"/paintings/"+id, nil), nil)
+	if status != http.StatusOK {
+		t.Fatalf("delete: %d %+v", status, res)
+	}
+	status, res = do(t, newRequest(t, "GET", server.URL+"/paintings/"+id, nil), nil)
+	if status != http.StatusNotFound || res.Error == "" {
+		t.Errorf("get deleted: %d %+v", status, res)
+	}
+}
+
+func TestPaintingRejectsInvalidInput(t *testing.T) {
+	server := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		fields map[string]string
+	}{
+		{name: "price", fields: map[string]string{"price": "cheap", "date": "2020-01-01T00:00:00Z", "materials": "[]", "size": `{"width": 1, "height": 1}`}},
+		{name: "date", fields: map[string]string{"price": "1", "date": "yesterday", "materials": "[]", "size": `{"width": 1, "height": 1}`}},
+		{name: "size", fields: map[string]string{"price": "1", "date": "2020-01-01T00:00:00Z", "materials": "[]", "size": `{"width": -1, "height": 1}`}},
+		{name: "material", fields: map[string]string{"price": "1", "date": "2020-01-01T00:00:00Z", "materials": `["unobtainium"]`, "size": `{"width": 1, "height": 1}`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, res := do(t, newRequest(t, "POST", server.URL+"/paintings/add", tt.fields), nil)
+			if status != http.StatusBadRequest || res.Error == "" {
+				t.Errorf("got %d %+v, want 400 with an error", status, res)
+			}
+		})
+	}
+}
+
+func TestPaintingUpdateRejectsInvalidInput(t *testing.T) {
+	server := newTestServer(t)
+
+	var id string
+	status, res := do(t, newRequest(t, "POST", server.URL+"/paintings/add", map[string]string{
+		"title":     "Sunflowers",
+		"price":     "1200",
+		"date":      "1888-08-01T00:00:00Z",
+		"materials": "[]",
+		"size":      `{"width": 73, "height": 92}`,
+	}), &id)
+	if status != http.StatusOK {
+		t.Fatalf("create: %d %+v", status, res)
+	}
+
+	tests := []struct {
+		name   string
+		fields map[string]string
+	}{
+		{name: "price", fields: map[string]string{"title": "Irises", "price": "cheap"}},
+		{name: "date", fields: map[string]string{"title": "Irises", "date": "yesterday"}},
+		{name: "watermark", fields: map[string]string{"title": "Irises", "watermark": "sometimes"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, res := do(t, newRequest(t, "PUT", server.URL+"/paintings/"+id, tt.fields), nil)
+			if status != http.StatusBadRequest || res.Error == "" {
+				t.Errorf("got %d %+v, want 400 with an error", status, res)
+			}
+		})
+	}
+
+	var painting models.Painting
+	res2, err := http.Get(server.URL + "/paintings/" + id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res2.Body.Close()
+	err = json.NewDecoder(res2.Body).Decode(&painting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if painting.Title != "Sunflowers" {
+		t.Errorf("rejected updates changed the title to %q", painting.Title)
+	}
+}
+
+func TestPaintingBadRequestsUseEnvelope(t *testing.T) {
+	server := newTestServer(t)
+
+	tests := []struct {
+		name string
+		req  *http.Request
+	}{
+		{name: "get bad id", req: newRequest(t, "GET", server.URL+"/paintings/nope", nil)},
+		{name: "delete bad id", req: newRequest(t, "DELETE", server.URL+"/paintings/nope", nil)},
+		{name: "update bad id", req: newRequest(t, "PUT", server.URL+"/paintings/nope", map[string]string{"title": "x"})},
+		{name: "add without form", req: newRequest(t, "POST", server.URL+"/paintings/add", nil)},
+		{name: "update without form", req: newRequest(t, "PUT", server.URL+"/paintings/"+"6ad4b635e945230d8598ad23", nil)},
+		{name: "page out of range", req: newRequest(t, "GET", server.URL+"/paintings?page=9223372036854775807", nil)},
+		{name: "page past the cap", req: newRequest(t, "GET", server.URL+"/paintings?page=1000001&per_page=100", nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, res := do(t, tt.req, nil)
+			if status != http.StatusBadRequest || res.Error == "" {
+				t.Errorf("got %d %+v, want 400 with an error", status, res)
+			}
+		})
+	}
+}
+
+func TestPaintingHidesOriginalsFolder(t *testing.T) {
+	server := newTestServer(t)
+
+	var id string
+	status, res := do(t, newRequest(t, "POST", server.URL+"/paintings/add", map[string]string{
+		"title":     "Sunflowers",
+		"price":     "1200",
+		"date":      "1888-08-01T00:00:00Z",
+		"materials": "[]",
+		"size":      `{"width": 73, "height": 92}`,
+	}), &id)
+	if status != http.StatusOK {
+		t.Fatalf("create: %d %+v", status, res)
+	}
+
+	get, err := http.Get(server.URL + "/paintings/" + id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer get.Body.Close()
+	var painting struct {
+		Photos map[string]json.RawMessage `json:"photos"`
+	}
+	err = json.NewDecoder(get.Body).Decode(&painting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := painting.Photos["OriginalsFolder"]; ok {
+		t.Errorf("response reveals where the originals are kept: %v", painting.Photos)
+	}
+}
+
+func TestPaintingCreateResponse(t *testing.T) {
+	server := newTestServer(t)
+	fields := map[string]string{
+		"title":     "Sunflowers",
+		"price":     "1200",
+		"date":      "1888-08-01T00:00:00Z",
+		"materials": "[]",
+		"size":      `{"width": 73, "height": 92}`,
+	}
+
+	// Without images, the data is the ID, as when creating anything else.
+	var id string
+	status, res := do(t, newRequest(t, "POST", server.URL+"/paintings/add", fields), &id)
+	if status != http.StatusOK || id == "" {
+		t.Errorf("create: %d %+v", status, res)
+	}
+
+	var accepted struct {
+		ID  string `json:"id"`
+		Job string `json:"job"`
+	}
+	status, res = do(t, imageRequest(t, "POST", server.URL+"/paintings/add", fields, "images"), &accepted)
+	if status != http.StatusAccepted || accepted.ID == "" || accepted.Job == "" {
+		t.Errorf("create with images: %d %+v %+v", status, res, accepted)
+	}
+}
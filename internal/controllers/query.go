@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"art/internal/models"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+	// maxPage keeps the number of paintings skipped from overflowing.
+	maxPage = 1000000
+)
+
+// parsePaintingQuery reads listing parameters from the query string:
+// page, per_page, availability, material, artist, collection, min_price, max_price, from, to and
+// sort (price, date or title, prefixed with "-" for descending order).
+func parsePaintingQuery(req *http.Request) (models.PaintingQuery, error) {
+	values := req.URL.Query()
+	q := models.PaintingQuery{Page: 1, PerPage: defaultPerPage}
+
+	var err error
+	if page := values.Get("page"); page != "" {
+		q.Page, err = strconv.Atoi(page)
+		if err != nil || q.Page < 1 || q.Page > maxPage {
+			return q, fmt.Errorf("Invalid page value: %q, must be between 1 and %d", page, maxPage)
+		}
+	}
+	if perPage := values.Get("per_page"); perPage != "" {
+		q.PerPage, err = strconv.Atoi(perPage)
+		if err != nil || q.PerPage < 1 || q.PerPage > maxPerPage {
+			return q, fmt.Errorf("Invalid per_page value: %q, must be between 1 and %d", perPage, maxPerPage)
+		}
+	}
+
+	if sort := values.Get("sort"); sort != "" {
+		if _, ok := models.SortFields[strings.TrimPrefix(sort, "-")]; !ok {
+			return q, fmt.Errorf("Invalid sort value: %q", sort)
+		}
+		q.Sort = sort
+	}
+
+	q.Filter, err = parsePaintingFilter(values)
+	return q, err
+}
+
+func parsePaintingFilter(values url.Values) (models.PaintingFilter, error) {
+	f := models.PaintingFilter{
+		Availability: values.Get("availability"),
+		MaterialID:   values.Get("material"),
+	}
+
+	if artist := values.Get("artist"); artist != "" {
+		id, err := primitive.ObjectIDFromHex(artist)
+		if err != nil {
+			return f, fmt.Errorf("Invalid artist value: %q", artist)
+		}
+		f.ArtistID = id
+	}
+	if collection := values.Get("collection"); collection != "" {
+		id, err := primitive.ObjectIDFromHex(collection)
+		if err != nil {
+			return f, fmt.Errorf("Invalid collection value: %q", collection)
+		}
+		f.CollectionID = id
+	}
+
+	var err error
+	if f.MinPrice, err = parseFloatParam(values, "min_price"); err != nil {
+		return f, err
+	}
+	if f.MaxPrice, err = parseFloatParam(values, "max_price"); err != nil {
+		return f, err
+	}
+	if f.From, err = parseDateParam(values, "from", false); err != nil {
+		return f, err
+	}
+	if f.To, err = parseDateParam(values, "to", true); err != nil {
+		return f, err
+	}
+	if f.MinWidth, err = parseLengthParam(values, "min_width"); err != nil {
+		return f, err
+	}
+	if f.MaxWidth, err = parseLengthParam(values, "max_width"); err != nil {
+		return f, err
+	}
+	if f.MinHeight, err = parseLengthParam(values, "min_height"); err != nil {
+		return f, err
+	}
+	if f.MaxHeight, err = parseLengthParam(values, "max_height"); err != nil {
+		return f, err
+	}
+
+	return f, nil
+}
+
+// parseLengthParam accepts lengths such as "100", "100cm" or "40in" and
+// returns them in centimeters.
+func parseLengthParam(values url.Values, name string) (*float64, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := models.ParseLength(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s value: %q, expected a length such as 100cm or 40in", name, raw)
+	}
+	return &value, nil
+}
+
+// parseUnit reads the unit sizes should be returned in, centimeters by default.
+func parseUnit(req *http.Request) (string, error) {
+	unit := req.URL.Query().Get("unit")
+	if unit == "" {
+		return models.UnitCentimeters, nil
+	}
+	if !models.ValidUnit(unit) {
+		return "", fmt.Errorf("Invalid unit value: %q, must be %q or %q", unit, models.UnitCentimeters, models.UnitInches)
+	}
+	return unit, nil
+}
+
+func convertSize(p *models.Painting, unit string) {
+	if p.Size != nil {
+		size := p.Size.In(unit)
+		p.Size = &size
+	}
+}
+
+func parseFloatParam(values url.Values, name string) (*float64, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s value: %v", name, err)
+	}
+	return &value, nil
+}
+
+// parseDateParam accepts RFC 3339 timestamps or plain dates. A plain date used
+// as an upper bound covers the whole day.
+func parseDateParam(values url.Values, name string, endOfDay bool) (*time.Time, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s value: %q, expected YYYY-MM-DD or RFC 3339", name, raw)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Millisecond)
+	}
+	return &t, nil
+}
+
+// pageLink returns the current request URL pointing at another page.
+func pageLink(req *http.Request, page int) string {
+	link := *req.URL
+	values := link.Query()
+	values.Set("page", strconv.Itoa(page))
+	link.RawQuery = values.Encode()
+	return link.String()
+}
+
+func newPageMeta(req *http.Request, q models.PaintingQuery, total int64) *Meta {
+	meta := &Meta{Total: total, Page: q.Page, PerPage: q.PerPage}
+	if int64(q.Page*q.PerPage) < total {
+		meta.Next = pageLink(req, q.Page+1)
+	}
+	if q.Page > 1 {
+		meta.Prev = pageLink(req, q.Page-1)
+	}
+	return meta
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"art/internal/models"
+	"art/internal/photoprocessor"
+	"art/internal/storage"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+type Response struct {
+	Data    interface{} `json:"data,omitempty"`
+	Meta    *Meta       `json:"meta,omitempty"`
+	Message string      `json:"message,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// Meta describes the page of a paginated listing.
+type Meta struct {
+	Total   int64  `json:"total"`
+	Page    int    `json:"page"`
+	PerPage int    `json:"perPage"`
+	Next    string `json:"next,omitempty"`
+	Prev    string `json:"prev,omitempty"`
+}
+
+func writeJSON(res http.ResponseWriter, status int, response Response) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	err := json.NewEncoder(res).Encode(response)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// uploadResult is the outcome of one image of a failed upload. Stored images
+// are removed again once the upload has failed.
+type uploadResult struct {
+	Name   string `json:"name"`
+	Stored bool   `json:"stored"`
+	Error  string `json:"error,omitempty"`
+}
+
+// imageError is why one uploaded image was rejected.
+type imageError struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// writeError maps state errors to HTTP statuses. Unexpected errors are logged
+// and reported as a generic internal error.
+func writeError(res http.ResponseWriter, err error) {
+	var uploadErr *storage.UploadError
+	var invalidErr *photoprocessor.ValidationError
+	switch {
+	case errors.As(err, &invalidErr):
+		rejected := make([]imageError, 0, len(invalidErr.Files))
+		for _, f := range invalidErr.Files {
+			rejected = append(rejected, imageError{Name: f.Name, Error: f.Err.Error()})
+		}
+		writeJSON(res, http.StatusBadRequest, Response{Data: rejected, Error: "invalid images"})
+	case errors.As(err, &uploadErr):
+		log.Println(err)
+		results := make([]uploadResult, 0, len(uploadErr.Results))
+		for _, r := range uploadErr.Results {
+			result := uploadResult{Name: r.Name, Stored: r.Err == nil}
+			if r.Err != nil {
+				result.Error = r.Err.Error()
+			}
+			results = append(results, result)
+		}
+		writeJSON(res, http.StatusBadGateway, Response{Data: results, Error: "could not upload images"})
+	case errors.Is(err, models.ErrNotFound):
+		writeJSON(res, http.StatusNotFound, Response{Error: err.Error()})
+	case errors.Is(err, models.ErrInvalidAvailability), errors.Is(err, models.ErrUnknownMaterial), errors.Is(err, models.ErrUnknownArtist), errors.Is(err, models.ErrUnknownPainting):
+		writeJSON(res, http.StatusBadRequest, Response{Error: err.Error()})
+	case errors.Is(err, models.ErrConflict):
+		writeJSON(res, http.StatusConflict, Response{Error: err.Error()})
+	default:
+		log.Println(err)
+		writeJSON(res, http.StatusInternalServerError, Response{Error: "internal error"})
+	}
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"context"
+	"log"
+)
+
+// unitOfWork makes the image store and database steps of a request behave as
+// one: if the request fails, the steps that completed are undone in reverse
+// order, and steps that cannot be undone, like deleting replaced images, only
+// run once every other step succeeded.
+//
+//	var work unitOfWork
+//	defer work.rollback(ctx)
+//	...
+//	work.commit(ctx)
+type unitOfWork struct {
+	undo      []func(context.Context) error
+	committed []func(context.Context) error
+	done      bool
+}
+
+// onRollback registers fn to undo a completed step.
+func (u *unitOfWork) onRollback(fn func(context.Context) error) {
+	u.undo = append(u.undo, fn)
+}
+
+// onCommit registers fn to run once the work is committed.
+func (u *unitOfWork) onCommit(fn func(context.Context) error) {
+	u.committed = append(u.committed, fn)
+}
+
+// commit runs the functions registered with onCommit, unless the work was
+// rolled back. Their errors are only logged, as the request itself
+// succeeded.
+func (u *unitOfWork) commit(ctx context.Context) {
+	if u.done {
+		return
+	}
+	u.done = true
+	u.run(ctx, u.committed)
+}
+
+// rollback undoes the completed steps unless the work was committed. It runs
+// even if ctx is cancelled, which is often why the request failed.
+func (u *unitOfWork) rollback(ctx context.Context) {
+	if u.done {
+		return
+	}
+	u.done = true
+
+	reversed := make([]func(context.Context) error, 0, len(u.undo))
+	for i := len(u.undo) - 1; i >= 0; i-- {
+		reversed = append(reversed, u.undo[i])
+	}
+	u.run(ctx, reversed)
+}
+
+func (u *unitOfWork) run(ctx context.Context, fns []func(context.Context) error) {
+	ctx = context.WithoutCancel(ctx)
+	for _, fn := range fns {
+		err := fn(ctx)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
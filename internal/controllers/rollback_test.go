@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// recorder returns steps for a unitOfWork, which append their name to the
+// returned log when run, or note that their context was cancelled.
+func recorder() (func(name string, err error) func(context.Context) error, *[]string) {
+	var ran []string
+	step := func(name string, err error) func(context.Context) error {
+		return func(ctx context.Context) error {
+			if ctx.Err() != nil {
+				ran = append(ran, name+" cancelled")
+				return ctx.Err()
+			}
+			ran = append(ran, name)
+			return err
+		}
+	}
+	return step, &ran
+}
+
+func TestUnitOfWorkRollback(t *testing.T) {
+	var work unitOfWork
+	step, ran := recorder()
+	work.onRollback(step("delete images", nil))
+	work.onRollback(step("delete painting", errors.New("failed")))
+	work.onRollback(step("cancel job", nil))
+	work.onCommit(step("delete old images", nil))
+
+	// The request failed because it was cancelled: cleanups run anyway.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	work.rollback(ctx)
+	work.rollback(ctx)
+	work.commit(ctx)
+
+	// A failing step does not stop the others.
+	want := []string{"cancel job", "delete painting", "delete images"}
+	if !reflect.DeepEqual(*ran, want) {
+		t.Errorf("ran %q, want %q", *ran, want)
+	}
+}
+
+func TestUnitOfWorkCommit(t *testing.T) {
+	var work unitOfWork
+	step, ran := recorder()
+	work.onRollback(step("delete images", nil))
+	work.onCommit(step("delete old images", nil))
+	work.onCommit(step("queue job", nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	work.commit(ctx)
+	cancel()
+	// Deferred, rollback runs after commit and must do nothing.
+	work.rollback(ctx)
+
+	want := []string{"delete old images", "queue job"}
+	if !reflect.DeepEqual(*ran, want) {
+		t.Errorf("ran %q, want %q", *ran, want)
+	}
+}
@@ -12,11 +12,13 @@ import (
 )
 
 type Validation struct {
-	req *http.Request
+	req       *http.Request
+	materials *models.Materials
+	artists   *models.Artists
 }
 
-func NewValidation(req *http.Request) *Validation {
-	return &Validation{req: req}
+func NewValidation(req *http.Request, materials *models.Materials, artists *models.Artists) *Validation {
+	return &Validation{req: req, materials: materials, artists: artists}
 }
 
 func (v *Validation) Validate(fields ...string) (models.Painting, error) {
@@ -53,7 +55,20 @@ func (v *Validation) Validate(fields ...string) (models.Painting, error) {
 		case "descriptionUkr":
 			painting.DescriptionUkr = v.req.FormValue("descriptionUkr")
 		case "availability":
-			painting.Availability = v.req.FormValue("availability")
+			err := v.validateAvailability(&painting)
+			if err != nil {
+				return models.Painting{}, err
+			}
+		case "artist":
+			err := v.validateArtist(&painting)
+			if err != nil {
+				return models.Painting{}, err
+			}
+		case "watermark":
+			err := v.validateWatermark(&painting)
+			if err != nil {
+				return models.Painting{}, err
+			}
 		}
 	}
 
@@ -81,19 +96,110 @@ func (v *Validation) validateDate(painting *models.Painting) error {
 }
 
 func (v *Validation) validateMaterials(painting *models.Painting) error {
-	materialsStr := v.req.FormValue("materials")
-	err := json.Unmarshal([]byte(materialsStr), &painting.Materials)
+	ids, err := parseMaterialIDs(v.req.FormValue("materials"))
 	if err != nil {
-		return fmt.Errorf("Invalid materials value: %v", err)
+		return err
+	}
+	painting.Materials, err = v.materials.Resolve(v.req.Context(), ids)
+	if err != nil {
+		return fmt.Errorf("Invalid materials value: %w", err)
 	}
 	return nil
 }
 
+// parseMaterialIDs accepts either a list of material IDs, ["oil", "canvas"],
+// or a list of material objects of which only the IDs are used. Labels always
+// come from the materials catalogue.
+func parseMaterialIDs(materialsStr string) ([]string, error) {
+	var ids []string
+	err := json.Unmarshal([]byte(materialsStr), &ids)
+	if err == nil {
+		return ids, nil
+	}
+
+	var materials []models.Material
+	err = json.Unmarshal([]byte(materialsStr), &materials)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid materials value: %v", err)
+	}
+	ids = make([]string, 0, len(materials))
+	for _, m := range materials {
+		ids = append(ids, m.ID)
+	}
+	return ids, nil
+}
+
 func (v *Validation) validateSize(painting *models.Painting) error {
-	sizeStr := v.req.FormValue("size")
-	err := json.Unmarshal([]byte(sizeStr), &painting.Size)
+	size, err := parseDimensions(v.req.FormValue("size"))
 	if err != nil {
-		return fmt.Errorf("Invalid size value: %v", err)
+		return err
 	}
+	painting.Size = size
 	return nil
 }
+
+// parseDimensions reads a JSON size such as {"width": 50, "height": 70,
+// "unit": "in"} and converts it to centimeters for storage.
+func parseDimensions(sizeStr string) (*models.Dimensions, error) {
+	var size models.Dimensions
+	err := json.Unmarshal([]byte(sizeStr), &size)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid size value: %v", err)
+	}
+	err = size.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid size value: %v", err)
+	}
+	size = size.In(models.UnitCentimeters)
+	return &size, nil
+}
+
+func (v *Validation) validateAvailability(painting *models.Painting) error {
+	availability := v.req.FormValue("availability")
+	if availability == "" {
+		availability = models.AvailabilityAvailable
+	}
+	if !models.ValidAvailability(availability) {
+		return fmt.Errorf("Invalid availability value: %q", availability)
+	}
+	painting.Availability = availability
+	return nil
+}
+
+// validateArtist links the painting to the artist given by its ID. The artist
+// is optional, but must exist when given.
+func (v *Validation) validateArtist(painting *models.Painting) error {
+	artistStr := v.req.FormValue("artist")
+	if artistStr == "" {
+		return nil
+	}
+	id, err := v.artists.Resolve(v.req.Context(), artistStr)
+	if err != nil {
+		return fmt.Errorf("Invalid artist value: %w", err)
+	}
+	painting.ArtistID = &id
+	return nil
+}
+
+// validateWatermark reads whether the images of the painting are watermarked.
+// Without a value they follow the gallery's watermark settings.
+func (v *Validation) validateWatermark(painting *models.Painting) error {
+	watermarkStr := v.req.FormValue("watermark")
+	if watermarkStr == "" {
+		return nil
+	}
+	watermark, err := parseWatermark(watermarkStr)
+	if err != nil {
+		return err
+	}
+	painting.Watermark = &watermark
+	return nil
+}
+
+func parseWatermark(watermarkStr string) (bool, error) {
+	watermark, err := strconv.ParseBool(watermarkStr)
+	if err != nil {
+		return false, fmt.Errorf("Invalid watermark value: %q", watermarkStr)
+	}
+	return watermark, nil
+}
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"art/internal/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoArtistState struct {
+	DB      *mongo.Database
+	artists mongoCollection[models.Artist]
+}
+
+func NewMongoArtistState(db *mongo.Database) *MongoArtistState {
+	return &MongoArtistState{DB: db, artists: newMongoCollection[models.Artist](db.Collection("artists"), "artist")}
+}
+
+func (a *MongoArtistState) List(ctx context.Context) ([]models.Artist, error) {
+	return a.artists.find(ctx, bson.M{}, bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}})
+}
+
+func (a *MongoArtistState) Save(ctx context.Context, artist models.Artist) (primitive.ObjectID, error) {
+	if artist.ID.IsZero() {
+		artist.ID = primitive.NewObjectID()
+	}
+
+	err := a.artists.insert(ctx, artist.ID, artist)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return artist.ID, nil
+}
+
+func (a *MongoArtistState) One(ctx context.Context, id primitive.ObjectID) (models.Artist, error) {
+	return a.artists.one(ctx, id)
+}
+
+func (a *MongoArtistState) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return a.artists.delete(ctx, id)
+}
+
+func (a *MongoArtistState) Update(ctx context.Context, id primitive.ObjectID, update bson.M) error {
+	return a.artists.update(ctx, id, update)
+}
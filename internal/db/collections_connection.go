@@ -0,0 +1,47 @@
+package db
+
+import (
+	"art/internal/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoCollectionState struct {
+	DB          *mongo.Database
+	collections mongoCollection[models.Collection]
+}
+
+func NewMongoCollectionState(db *mongo.Database) *MongoCollectionState {
+	return &MongoCollectionState{DB: db, collections: newMongoCollection[models.Collection](db.Collection("collections"), "collection")}
+}
+
+func (c *MongoCollectionState) List(ctx context.Context) ([]models.Collection, error) {
+	return c.collections.find(ctx, bson.M{}, bson.D{{Key: "title", Value: 1}, {Key: "_id", Value: 1}})
+}
+
+func (c *MongoCollectionState) Save(ctx context.Context, collection models.Collection) (primitive.ObjectID, error) {
+	if collection.ID.IsZero() {
+		collection.ID = primitive.NewObjectID()
+	}
+
+	err := c.collections.insert(ctx, collection.ID, collection)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return collection.ID, nil
+}
+
+func (c *MongoCollectionState) One(ctx context.Context, id primitive.ObjectID) (models.Collection, error) {
+	return c.collections.one(ctx, id)
+}
+
+func (c *MongoCollectionState) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return c.collections.delete(ctx, id)
+}
+
+func (c *MongoCollectionState) Update(ctx context.Context, id primitive.ObjectID, update bson.M) error {
+	return c.collections.update(ctx, id, update)
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"art/internal/models"
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoJobState struct {
+	DB   *mongo.Database
+	jobs mongoCollection[models.Job]
+}
+
+func NewMongoJobState(db *mongo.Database) *MongoJobState {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	_, err := db.Collection("jobs").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "created", Value: 1}},
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	return &MongoJobState{DB: db, jobs: newMongoCollection[models.Job](db.Collection("jobs"), "job")}
+}
+
+func (j *MongoJobState) Save(ctx context.Context, job models.Job) (primitive.ObjectID, error) {
+	if job.ID.IsZero() {
+		job.ID = primitive.NewObjectID()
+	}
+
+	err := j.jobs.insert(ctx, job.ID, job)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return job.ID, nil
+}
+
+func (j *MongoJobState) One(ctx context.Context, id primitive.ObjectID) (models.Job, error) {
+	return j.jobs.one(ctx, id)
+}
+
+func (j *MongoJobState) Update(ctx context.Context, id primitive.ObjectID, update bson.M) error {
+	return j.jobs.update(ctx, id, update)
+}
+
+func (j *MongoJobState) Unfinished(ctx context.Context) ([]models.Job, error) {
+	filter := bson.M{"status": bson.M{"$in": bson.A{models.JobQueued, models.JobRunning}}}
+	return j.jobs.find(ctx, filter, bson.D{{Key: "created", Value: 1}, {Key: "_id", Value: 1}})
+}
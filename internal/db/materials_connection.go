@@ -0,0 +1,108 @@
+package db
+
+import (
+	"art/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoMaterialState struct {
+	DB *mongo.Database
+}
+
+func NewMongoMaterialState(db *mongo.Database) *MongoMaterialState {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	_, err := db.Collection("materials").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Println(err)
+	}
+
+	return &MongoMaterialState{DB: db}
+}
+
+func (m *MongoMaterialState) List(ctx context.Context) ([]models.Material, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.M{"id": 1})
+	cursor, err := m.DB.Collection("materials").Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	materials := []models.Material{}
+	if err = cursor.All(ctx, &materials); err != nil {
+		return nil, err
+	}
+
+	return materials, nil
+}
+
+func (m *MongoMaterialState) Save(ctx context.Context, material models.Material) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	_, err := m.DB.Collection("materials").InsertOne(ctx, material)
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("material %s: %w", material.ID, models.ErrConflict)
+	}
+	return err
+}
+
+func (m *MongoMaterialState) One(ctx context.Context, id string) (models.Material, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	var material models.Material
+	err := m.DB.Collection("materials").FindOne(ctx, bson.M{"id": id}).Decode(&material)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.Material{}, fmt.Errorf("material %s: %w", id, models.ErrNotFound)
+	}
+	if err != nil {
+		return models.Material{}, err
+	}
+
+	return material, nil
+}
+
+func (m *MongoMaterialState) Delete(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	res, err := m.DB.Collection("materials").DeleteOne(ctx, bson.M{"id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("material %s: %w", id, models.ErrNotFound)
+	}
+	return nil
+}
+
+func (m *MongoMaterialState) Update(ctx context.Context, material models.Material) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"en": material.EN, "ukr": material.UKR}}
+	res, err := m.DB.Collection("materials").UpdateOne(ctx, bson.M{"id": material.ID}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("material %s: %w", material.ID, models.ErrNotFound)
+	}
+	return nil
+}
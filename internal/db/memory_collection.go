@@ -0,0 +1,430 @@
+package db
+
+import (
+	"art/internal/models"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryCollection is a concurrency-safe set of documents keyed by ObjectID.
+// Documents are stored as BSON, so reads return independent copies and
+// updates use the same field names and operators as in Mongo.
+type memoryCollection[T any] struct {
+	name  string
+	mu    sync.RWMutex
+	order []primitive.ObjectID
+	docs  map[primitive.ObjectID]bson.Raw
+}
+
+// newMemoryCollection creates an empty collection. name is used in errors.
+func newMemoryCollection[T any](name string) *memoryCollection[T] {
+	return &memoryCollection[T]{
+		name: name,
+		docs: make(map[primitive.ObjectID]bson.Raw),
+	}
+}
+
+func (c *memoryCollection[T]) insert(id primitive.ObjectID, doc T) error {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.docs[id]; exists {
+		return fmt.Errorf("%s %s: %w", c.name, id.Hex(), models.ErrConflict)
+	}
+	c.docs[id] = raw
+	c.order = append(c.order, id)
+	return nil
+}
+
+func (c *memoryCollection[T]) one(id primitive.ObjectID) (T, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var doc T
+	raw, ok := c.docs[id]
+	if !ok {
+		return doc, fmt.Errorf("%s %s: %w", c.name, id.Hex(), models.ErrNotFound)
+	}
+	err := bson.Unmarshal(raw, &doc)
+	return doc, err
+}
+
+// all returns every document in insertion order.
+func (c *memoryCollection[T]) all() ([]T, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	docs := make([]T, 0, len(c.order))
+	for _, id := range c.order {
+		var doc T
+		if err := bson.Unmarshal(c.docs[id], &doc); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+func (c *memoryCollection[T]) delete(id primitive.ObjectID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.docs[id]; !ok {
+		return fmt.Errorf("%s %s: %w", c.name, id.Hex(), models.ErrNotFound)
+	}
+	delete(c.docs, id)
+	for i, existing := range c.order {
+		if existing == id {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// update applies a Mongo update document to the document with the given ID if
+// it matches cond, and returns ErrConflict if it does not.
+func (c *memoryCollection[T]) update(id primitive.ObjectID, cond bson.M, update bson.M) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	raw, ok := c.docs[id]
+	if !ok {
+		return fmt.Errorf("%s %s: %w", c.name, id.Hex(), models.ErrNotFound)
+	}
+
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+
+	matched, err := matches(doc, cond)
+	if err != nil {
+		return err
+	}
+	if !matched {
+		return fmt.Errorf("%s %s was changed concurrently: %w", c.name, id.Hex(), models.ErrConflict)
+	}
+
+	if err := applyUpdate(doc, update); err != nil {
+		return err
+	}
+
+	// Round-trip through T so a bad update cannot store a document that
+	// later reads fail to decode.
+	var typed T
+	updated, err := bson.Marshal(doc)
+	if err == nil {
+		err = bson.Unmarshal(updated, &typed)
+	}
+	if err == nil {
+		updated, err = bson.Marshal(typed)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.docs[id] = updated
+	return nil
+}
+
+// updateEach calls fn for every document and stores those it reports changed.
+func (c *memoryCollection[T]) updateEach(fn func(*T) bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, raw := range c.docs {
+		var doc T
+		if err := bson.Unmarshal(raw, &doc); err != nil {
+			return err
+		}
+		if !fn(&doc) {
+			continue
+		}
+
+		updated, err := bson.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		c.docs[id] = updated
+	}
+	return nil
+}
+
+// applyUpdate applies a Mongo update document ($set, $unset, $inc, $push and
+// $pull with dotted field paths) to doc in place.
+func applyUpdate(doc bson.M, update bson.M) error {
+	for op, arg := range update {
+		fields, err := normalize(arg)
+		if err != nil {
+			return err
+		}
+		fieldsDoc, ok := fields.(bson.M)
+		if !ok {
+			return fmt.Errorf("%s: expected a document, got %T", op, arg)
+		}
+
+		for path, value := range fieldsDoc {
+			if path == "_id" {
+				return errors.New("the _id field cannot be updated")
+			}
+			switch op {
+			case "$set":
+				err = setPath(doc, path, value)
+			case "$unset":
+				err = unsetPath(doc, path)
+			case "$inc":
+				err = incPath(doc, path, value)
+			case "$push":
+				err = pushPath(doc, path, value)
+			case "$pull":
+				err = pullPath(doc, path, value)
+			default:
+				err = fmt.Errorf("unsupported update operator %q", op)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// matches reports whether doc satisfies cond. Conditions are equality or $in
+// checks on dotted paths; as in Mongo, a nil value also matches a missing field.
+func matches(doc bson.M, cond bson.M) (bool, error) {
+	if len(cond) == 0 {
+		return true, nil
+	}
+	normalized, err := normalize(cond)
+	if err != nil {
+		return false, err
+	}
+
+	for path, want := range normalized.(bson.M) {
+		got, _, err := getPath(doc, path)
+		if err != nil {
+			return false, err
+		}
+
+		candidates := primitive.A{want}
+		if operator, ok := want.(bson.M); ok {
+			in, ok := operator["$in"].(primitive.A)
+			if !ok || len(operator) != 1 {
+				return false, fmt.Errorf("unsupported condition on %q", path)
+			}
+			candidates = in
+		}
+
+		found := false
+		for _, candidate := range candidates {
+			if reflect.DeepEqual(got, candidate) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// normalize converts an arbitrary Go value into the form it takes after a BSON
+// round trip, so structs and maps passed in updates compare equal to stored data.
+func normalize(value interface{}) (interface{}, error) {
+	raw, err := bson.Marshal(bson.M{"v": value})
+	if err != nil {
+		return nil, err
+	}
+	var doc bson.M
+	if err = bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return doc["v"], nil
+}
+
+// parent walks doc along all but the last element of path and returns the
+// container holding the final field together with that field's name.
+func parent(doc bson.M, path string, create bool) (interface{}, string, error) {
+	parts := strings.Split(path, ".")
+	var current interface{} = doc
+
+	for _, part := range parts[:len(parts)-1] {
+		var next interface{}
+		switch c := current.(type) {
+		case bson.M:
+			child, ok := c[part]
+			if !ok || child == nil {
+				if !create {
+					return nil, "", nil
+				}
+				child = bson.M{}
+				c[part] = child
+			}
+			next = child
+		case primitive.A:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, "", fmt.Errorf("cannot traverse %q: invalid array index %q", path, part)
+			}
+			next = c[i]
+		default:
+			return nil, "", fmt.Errorf("cannot traverse %q: %q is not a document", path, part)
+		}
+		current = next
+	}
+
+	return current, parts[len(parts)-1], nil
+}
+
+func getPath(doc bson.M, path string) (interface{}, bool, error) {
+	container, field, err := parent(doc, path, false)
+	if err != nil || container == nil {
+		return nil, false, err
+	}
+	switch c := container.(type) {
+	case bson.M:
+		value, ok := c[field]
+		return value, ok, nil
+	case primitive.A:
+		i, err := strconv.Atoi(field)
+		if err != nil || i < 0 || i >= len(c) {
+			return nil, false, nil
+		}
+		return c[i], true, nil
+	}
+	return nil, false, nil
+}
+
+func setPath(doc bson.M, path string, value interface{}) error {
+	container, field, err := parent(doc, path, true)
+	if err != nil {
+		return err
+	}
+	switch c := container.(type) {
+	case bson.M:
+		c[field] = value
+		return nil
+	case primitive.A:
+		i, err := strconv.Atoi(field)
+		if err != nil || i < 0 || i >= len(c) {
+			return fmt.Errorf("cannot set %q: invalid array index %q", path, field)
+		}
+		c[i] = value
+		return nil
+	}
+	return fmt.Errorf("cannot set %q: parent is not a document", path)
+}
+
+func unsetPath(doc bson.M, path string) error {
+	container, field, err := parent(doc, path, false)
+	if err != nil {
+		return err
+	}
+	switch c := container.(type) {
+	case bson.M:
+		delete(c, field)
+	case primitive.A:
+		if i, err := strconv.Atoi(field); err == nil && i >= 0 && i < len(c) {
+			c[i] = nil
+		}
+	}
+	return nil
+}
+
+func incPath(doc bson.M, path string, value interface{}) error {
+	current, exists, err := getPath(doc, path)
+	if err != nil {
+		return err
+	}
+	if !exists || current == nil {
+		current = int32(0)
+	}
+
+	switch by := value.(type) {
+	case int32:
+		switch c := current.(type) {
+		case int32:
+			return setPath(doc, path, c+by)
+		case int64:
+			return setPath(doc, path, c+int64(by))
+		case float64:
+			return setPath(doc, path, c+float64(by))
+		}
+	case int64:
+		switch c := current.(type) {
+		case int32:
+			return setPath(doc, path, int64(c)+by)
+		case int64:
+			return setPath(doc, path, c+by)
+		case float64:
+			return setPath(doc, path, c+float64(by))
+		}
+	case float64:
+		switch c := current.(type) {
+		case int32:
+			return setPath(doc, path, float64(c)+by)
+		case int64:
+			return setPath(doc, path, float64(c)+by)
+		case float64:
+			return setPath(doc, path, c+by)
+		}
+	default:
+		return fmt.Errorf("cannot $inc %q by non-numeric %T", path, value)
+	}
+	return fmt.Errorf("cannot $inc non-numeric field %q", path)
+}
+
+func pushPath(doc bson.M, path string, value interface{}) error {
+	current, exists, err := getPath(doc, path)
+	if err != nil {
+		return err
+	}
+
+	var array primitive.A
+	if exists && current != nil {
+		var ok bool
+		if array, ok = current.(primitive.A); !ok {
+			return fmt.Errorf("cannot $push to non-array field %q", path)
+		}
+	}
+
+	if modifier, ok := value.(bson.M); ok {
+		if each, ok := modifier["$each"].(primitive.A); ok {
+			return setPath(doc, path, append(array, each...))
+		}
+	}
+	return setPath(doc, path, append(array, value))
+}
+
+func pullPath(doc bson.M, path string, value interface{}) error {
+	current, exists, err := getPath(doc, path)
+	if err != nil || !exists || current == nil {
+		return err
+	}
+	array, ok := current.(primitive.A)
+	if !ok {
+		return fmt.Errorf("cannot $pull from non-array field %q", path)
+	}
+
+	kept := primitive.A{}
+	for _, element := range array {
+		if !reflect.DeepEqual(element, value) {
+			kept = append(kept, element)
+		}
+	}
+	return setPath(doc, path, kept)
+}
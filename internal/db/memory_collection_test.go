@@ -0,0 +1,277 @@
+package db
+
+import (
+	"art/internal/models"
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type testMeta struct {
+	Note string `bson:"note,omitempty"`
+	Hits int    `bson:"hits"`
+}
+
+type testDoc struct {
+	ID    primitive.ObjectID `bson:"_id"`
+	Title string             `bson:"title,omitempty"`
+	Count int                `bson:"count"`
+	Tags  []string           `bson:"tags,omitempty"`
+	Meta  testMeta           `bson:"meta"`
+}
+
+func newTestCollection(t *testing.T) (*memoryCollection[testDoc], primitive.ObjectID) {
+	t.Helper()
+	c := newMemoryCollection[testDoc]("doc")
+	id := primitive.NewObjectID()
+	err := c.insert(id, testDoc{ID: id, Title: "Sunflowers", Count: 1, Tags: []string{"oil", "canvas"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, id
+}
+
+func TestMemoryCollectionUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		update bson.M
+		want   func(d *testDoc)
+	}{
+		{
+			name:   "set",
+			update: bson.M{"$set": bson.M{"title": "Irises"}},
+			want:   func(d *testDoc) { d.Title = "Irises" },
+		},
+		{
+			name:   "set dotted path",
+			update: bson.M{"$set": bson.M{"meta.note": "restored"}},
+			want:   func(d *testDoc) { d.Meta.Note = "restored" },
+		},
+		{
+			name:   "set struct",
+			update: bson.M{"$set": bson.M{"meta": testMeta{Note: "lent", Hits: 3}}},
+			want:   func(d *testDoc) { d.Meta = testMeta{Note: "lent", Hits: 3} },
+		},
+		{
+			name:   "set array element",
+			update: bson.M{"$set": bson.M{"tags.1": "panel"}},
+			want:   func(d *testDoc) { d.Tags = []string{"oil", "panel"} },
+		},
+		{
+			name:   "unset",
+			update: bson.M{"$unset": bson.M{"title": ""}},
+			want:   func(d *testDoc) { d.Title = "" },
+		},
+		{
+			name:   "unset missing field",
+			update: bson.M{"$unset": bson.M{"meta.note": ""}},
+			want:   func(d *testDoc) {},
+		},
+		{
+			name:   "inc",
+			update: bson.M{"$inc": bson.M{"count": 2}},
+			want:   func(d *testDoc) { d.Count = 3 },
+		},
+		{
+			name:   "inc missing dotted path",
+			update: bson.M{"$inc": bson.M{"meta.hits": 1}},
+			want:   func(d *testDoc) { d.Meta.Hits = 1 },
+		},
+		{
+			name:   "push",
+			update: bson.M{"$push": bson.M{"tags": "varnish"}},
+			want:   func(d *testDoc) { d.Tags = []string{"oil", "canvas", "varnish"} },
+		},
+		{
+			name:   "push each",
+			update: bson.M{"$push": bson.M{"tags": bson.M{"$each": []string{"gold", "leaf"}}}},
+			want:   func(d *testDoc) { d.Tags = []string{"oil", "canvas", "gold", "leaf"} },
+		},
+		{
+			name:   "pull",
+			update: bson.M{"$pull": bson.M{"tags": "oil"}},
+			want:   func(d *testDoc) { d.Tags = []string{"canvas"} },
+		},
+		{
+			name:   "several operators",
+			update: bson.M{"$set": bson.M{"title": "Irises"}, "$inc": bson.M{"count": -1}},
+			want:   func(d *testDoc) { d.Title = "Irises"; d.Count = 0 },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, id := newTestCollection(t)
+			want, err := c.one(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.want(&want)
+
+			err = c.update(id, nil, tt.update)
+			if err != nil {
+				t.Fatalf("update: %v", err)
+			}
+			got, err := c.one(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestMemoryCollectionUpdateErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		update bson.M
+	}{
+		{name: "unknown operator", update: bson.M{"$rename": bson.M{"title": "name"}}},
+		{name: "id", update: bson.M{"$set": bson.M{"_id": primitive.NewObjectID()}}},
+		{name: "inc non-numeric field", update: bson.M{"$inc": bson.M{"title": 1}}},
+		{name: "push to non-array", update: bson.M{"$push": bson.M{"title": "x"}}},
+		{name: "decodes badly", update: bson.M{"$set": bson.M{"count": "many"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, id := newTestCollection(t)
+			before, _ := c.one(id)
+
+			err := c.update(id, nil, tt.update)
+			if err == nil {
+				t.Fatal("update succeeded")
+			}
+			after, _ := c.one(id)
+			if !reflect.DeepEqual(before, after) {
+				t.Errorf("failed update changed the document to %+v", after)
+			}
+		})
+	}
+}
+
+func TestMemoryCollectionNotFound(t *testing.T) {
+	c, _ := newTestCollection(t)
+	missing := primitive.NewObjectID()
+
+	_, err := c.one(missing)
+	if !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("one: got %v, want ErrNotFound", err)
+	}
+	err = c.update(missing, nil, bson.M{"$set": bson.M{"title": "x"}})
+	if !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("update: got %v, want ErrNotFound", err)
+	}
+	err = c.delete(missing)
+	if !errors.Is(err, models.ErrNotFound) {
+		t.Errorf("delete: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryCollectionInsertConflict(t *testing.T) {
+	c, id := newTestCollection(t)
+
+	err := c.insert(id, testDoc{ID: id})
+	if !errors.Is(err, models.ErrConflict) {
+		t.Errorf("got %v, want ErrConflict", err)
+	}
+}
+
+func TestMemoryCollectionConditionalUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cond    bson.M
+		applied bool
+	}{
+		{name: "equal", cond: bson.M{"title": "Sunflowers"}, applied: true},
+		{name: "different", cond: bson.M{"title": "Irises"}},
+		{name: "dotted path", cond: bson.M{"meta.hits": 0}, applied: true},
+		{name: "in", cond: bson.M{"title": bson.M{"$in": []string{"Irises", "Sunflowers"}}}, applied: true},
+		{name: "not in", cond: bson.M{"title": bson.M{"$in": []string{"Irises"}}}},
+		{name: "nil matches missing", cond: bson.M{"meta.note": nil}, applied: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, id := newTestCollection(t)
+
+			err := c.update(id, tt.cond, bson.M{"$set": bson.M{"count": 7}})
+			if tt.applied && err != nil {
+				t.Fatalf("update: %v", err)
+			}
+			if !tt.applied && !errors.Is(err, models.ErrConflict) {
+				t.Fatalf("got %v, want ErrConflict", err)
+			}
+
+			got, _ := c.one(id)
+			if applied := got.Count == 7; applied != tt.applied {
+				t.Errorf("applied = %v, want %v", applied, tt.applied)
+			}
+		})
+	}
+}
+
+func TestMemoryGalleryStateUpdateIfConflict(t *testing.T) {
+	ctx := context.Background()
+	state := NewMemoryGalleryState()
+	id, err := state.Save(ctx, models.Painting{Title: "Sunflowers", Availability: models.AvailabilityAvailable})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two writers read the same availability; only the first may change it.
+	cond := bson.M{"availability": models.AvailabilityAvailable}
+	err = state.UpdateIf(ctx, id, cond, bson.M{"$set": bson.M{"availability": models.AvailabilityReserved}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = state.UpdateIf(ctx, id, cond, bson.M{"$set": bson.M{"availability": models.AvailabilitySold}})
+	if !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("got %v, want ErrConflict", err)
+	}
+
+	painting, err := state.One(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if painting.Availability != models.AvailabilityReserved {
+		t.Errorf("availability = %q, want %q", painting.Availability, models.AvailabilityReserved)
+	}
+}
+
+func TestMemoryCollectionConcurrentUpdates(t *testing.T) {
+	c, id := newTestCollection(t)
+
+	const writers = 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := c.update(id, nil, bson.M{"$inc": bson.M{"count": 1}, "$push": bson.M{"tags": "x"}})
+			if err != nil {
+				t.Error(err)
+			}
+			_, err = c.all()
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, _ := c.one(id)
+	if got.Count != 1+writers {
+		t.Errorf("count = %d, want %d", got.Count, 1+writers)
+	}
+	if len(got.Tags) != 2+writers {
+		t.Errorf("%d tags, want %d", len(got.Tags), 2+writers)
+	}
+}
@@ -0,0 +1,400 @@
+package db
+
+import (
+	"art/internal/models"
+	"art/internal/types"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryGalleryState keeps paintings in process memory.
+type MemoryGalleryState struct {
+	paintings *memoryCollection[models.Painting]
+}
+
+func NewMemoryGalleryState() *MemoryGalleryState {
+	return &MemoryGalleryState{
+		paintings: newMemoryCollection[models.Painting]("painting"),
+	}
+}
+
+func (w *MemoryGalleryState) List(ctx context.Context, q models.PaintingQuery) ([]models.Painting, int64, error) {
+	paintings, err := w.paintings.all()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	page, total := q.Apply(paintings)
+	return page, total, nil
+}
+
+func (w *MemoryGalleryState) UpdateMaterial(ctx context.Context, m models.Material) error {
+	return w.paintings.updateEach(func(painting *models.Painting) bool {
+		changed := false
+		for i := range painting.Materials {
+			if painting.Materials[i].ID == m.ID {
+				painting.Materials[i].EN = m.EN
+				painting.Materials[i].UKR = m.UKR
+				changed = true
+			}
+		}
+		return changed
+	})
+}
+
+func (w *MemoryGalleryState) Materials(ctx context.Context) ([]models.Material, error) {
+	paintings, err := w.paintings.all()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	materials := []models.Material{}
+	for _, p := range paintings {
+		for _, m := range p.Materials {
+			if !seen[m.ID] {
+				seen[m.ID] = true
+				materials = append(materials, m)
+			}
+		}
+	}
+	sort.Slice(materials, func(i, j int) bool { return materials[i].ID < materials[j].ID })
+	return materials, nil
+}
+
+func (w *MemoryGalleryState) Search(ctx context.Context, terms []string, limit int) ([]models.SearchResult, error) {
+	paintings, err := w.paintings.all()
+	if err != nil {
+		return nil, err
+	}
+
+	results := models.SearchPaintings(paintings, terms, limit)
+	if results == nil {
+		results = []models.SearchResult{}
+	}
+	return results, nil
+}
+
+func (w *MemoryGalleryState) Facets(ctx context.Context, f models.PaintingFilter) (models.Facets, error) {
+	paintings, err := w.paintings.all()
+	if err != nil {
+		return models.Facets{}, err
+	}
+
+	counter := models.FacetCounter{}
+	for _, p := range paintings {
+		if f.Match(p) {
+			counter.Count(p)
+		}
+	}
+	return counter.Facets(), nil
+}
+
+func (w *MemoryGalleryState) Save(ctx context.Context, p models.Painting) (primitive.ObjectID, error) {
+	if p.ID.IsZero() {
+		p.ID = primitive.NewObjectID()
+	}
+
+	err := w.paintings.insert(p.ID, p)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return p.ID, nil
+}
+
+func (w *MemoryGalleryState) One(ctx context.Context, id primitive.ObjectID) (models.Painting, error) {
+	return w.paintings.one(id)
+}
+
+func (w *MemoryGalleryState) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return w.paintings.delete(id)
+}
+
+func (w *MemoryGalleryState) Update(ctx context.Context, id primitive.ObjectID, update bson.M) error {
+	return w.paintings.update(id, nil, update)
+}
+
+func (w *MemoryGalleryState) UpdateIf(ctx context.Context, id primitive.ObjectID, cond bson.M, update bson.M) error {
+	return w.paintings.update(id, cond, update)
+}
+
+// MemoryUserState keeps users and sessions in process memory.
+type MemoryUserState struct {
+	mu            sync.RWMutex
+	users         map[types.Login]models.User
+	authenticated map[string]types.Login
+}
+
+func NewMemoryUserState() *MemoryUserState {
+	return &MemoryUserState{
+		users:         make(map[types.Login]models.User),
+		authenticated: make(map[string]types.Login),
+	}
+}
+
+func (u *MemoryUserState) Register(ctx context.Context, user models.User) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if _, exists := u.users[user.Login]; exists {
+		return errors.New("user already exists")
+	}
+	u.users[user.Login] = user
+
+	log.Printf("Inserted a new user with login: %s", user.Login)
+
+	return nil
+}
+
+func (u *MemoryUserState) Login(ctx context.Context, user models.User) (models.User, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	result, exists := u.users[user.Login]
+	if !exists {
+		return models.User{}, errors.New("user not found")
+	}
+
+	if result.Password != user.Password {
+		return models.User{}, errors.New("invalid password")
+	}
+
+	return result, nil
+}
+
+func (u *MemoryUserState) User(ctx context.Context, login types.Login) (models.User, error) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	user, exists := u.users[login]
+	if !exists {
+		return models.User{}, fmt.Errorf("user %s: %w", login, models.ErrNotFound)
+	}
+	return user, nil
+}
+
+func (u *MemoryUserState) SetAuthenticated(ctx context.Context, session string, login types.Login) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.authenticated[session] = login
+}
+
+func (u *MemoryUserState) IsAuthenticated(ctx context.Context, session string) (types.Login, bool) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	login, exists := u.authenticated[session]
+	return login, exists
+}
+
+// MemoryMaterialState keeps the materials catalogue in process memory.
+type MemoryMaterialState struct {
+	mu        sync.RWMutex
+	materials map[string]models.Material
+}
+
+func NewMemoryMaterialState() *MemoryMaterialState {
+	return &MemoryMaterialState{
+		materials: make(map[string]models.Material),
+	}
+}
+
+func (m *MemoryMaterialState) List(ctx context.Context) ([]models.Material, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	materials := make([]models.Material, 0, len(m.materials))
+	for _, material := range m.materials {
+		materials = append(materials, material)
+	}
+	sort.Slice(materials, func(i, j int) bool { return materials[i].ID < materials[j].ID })
+
+	return materials, nil
+}
+
+func (m *MemoryMaterialState) Save(ctx context.Context, material models.Material) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.materials[material.ID]; exists {
+		return fmt.Errorf("material %s: %w", material.ID, models.ErrConflict)
+	}
+	m.materials[material.ID] = material
+	return nil
+}
+
+func (m *MemoryMaterialState) One(ctx context.Context, id string) (models.Material, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	material, exists := m.materials[id]
+	if !exists {
+		return models.Material{}, fmt.Errorf("material %s: %w", id, models.ErrNotFound)
+	}
+	return material, nil
+}
+
+func (m *MemoryMaterialState) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.materials[id]; !exists {
+		return fmt.Errorf("material %s: %w", id, models.ErrNotFound)
+	}
+	delete(m.materials, id)
+	return nil
+}
+
+func (m *MemoryMaterialState) Update(ctx context.Context, material models.Material) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.materials[material.ID]; !exists {
+		return fmt.Errorf("material %s: %w", material.ID, models.ErrNotFound)
+	}
+	m.materials[material.ID] = material
+	return nil
+}
+
+// MemoryArtistState keeps artists in process memory.
+type MemoryArtistState struct {
+	artists *memoryCollection[models.Artist]
+}
+
+func NewMemoryArtistState() *MemoryArtistState {
+	return &MemoryArtistState{
+		artists: newMemoryCollection[models.Artist]("artist"),
+	}
+}
+
+func (a *MemoryArtistState) List(ctx context.Context) ([]models.Artist, error) {
+	artists, err := a.artists.all()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(artists, func(i, j int) bool { return artists[i].Name < artists[j].Name })
+	return artists, nil
+}
+
+func (a *MemoryArtistState) Save(ctx context.Context, artist models.Artist) (primitive.ObjectID, error) {
+	if artist.ID.IsZero() {
+		artist.ID = primitive.NewObjectID()
+	}
+
+	err := a.artists.insert(artist.ID, artist)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return artist.ID, nil
+}
+
+func (a *MemoryArtistState) One(ctx context.Context, id primitive.ObjectID) (models.Artist, error) {
+	return a.artists.one(id)
+}
+
+func (a *MemoryArtistState) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return a.artists.delete(id)
+}
+
+func (a *MemoryArtistState) Update(ctx context.Context, id primitive.ObjectID, update bson.M) error {
+	return a.artists.update(id, nil, update)
+}
+
+// MemoryCollectionState keeps collections in process memory.
+type MemoryCollectionState struct {
+	collections *memoryCollection[models.Collection]
+}
+
+func NewMemoryCollectionState() *MemoryCollectionState {
+	return &MemoryCollectionState{
+		collections: newMemoryCollection[models.Collection]("collection"),
+	}
+}
+
+func (c *MemoryCollectionState) List(ctx context.Context) ([]models.Collection, error) {
+	collections, err := c.collections.all()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(collections, func(i, j int) bool { return collections[i].Title < collections[j].Title })
+	return collections, nil
+}
+
+func (c *MemoryCollectionState) Save(ctx context.Context, collection models.Collection) (primitive.ObjectID, error) {
+	if collection.ID.IsZero() {
+		collection.ID = primitive.NewObjectID()
+	}
+
+	err := c.collections.insert(collection.ID, collection)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return collection.ID, nil
+}
+
+func (c *MemoryCollectionState) One(ctx context.Context, id primitive.ObjectID) (models.Collection, error) {
+	return c.collections.one(id)
+}
+
+func (c *MemoryCollectionState) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return c.collections.delete(id)
+}
+
+func (c *MemoryCollectionState) Update(ctx context.Context, id primitive.ObjectID, update bson.M) error {
+	return c.collections.update(id, nil, update)
+}
+
+// MemoryJobState keeps jobs in process memory, so they do not survive a
+// restart.
+type MemoryJobState struct {
+	jobs *memoryCollection[models.Job]
+}
+
+func NewMemoryJobState() *MemoryJobState {
+	return &MemoryJobState{
+		jobs: newMemoryCollection[models.Job]("job"),
+	}
+}
+
+func (j *MemoryJobState) Save(ctx context.Context, job models.Job) (primitive.ObjectID, error) {
+	if job.ID.IsZero() {
+		job.ID = primitive.NewObjectID()
+	}
+
+	err := j.jobs.insert(job.ID, job)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return job.ID, nil
+}
+
+func (j *MemoryJobState) One(ctx context.Context, id primitive.ObjectID) (models.Job, error) {
+	return j.jobs.one(id)
+}
+
+func (j *MemoryJobState) Update(ctx context.Context, id primitive.ObjectID, update bson.M) error {
+	return j.jobs.update(id, nil, update)
+}
+
+func (j *MemoryJobState) Unfinished(ctx context.Context) ([]models.Job, error) {
+	jobs, err := j.jobs.all()
+	if err != nil {
+		return nil, err
+	}
+
+	unfinished := []models.Job{}
+	for _, job := range jobs {
+		if !job.Finished() {
+			unfinished = append(unfinished, job)
+		}
+	}
+	return unfinished, nil
+}
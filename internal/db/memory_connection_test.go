@@ -0,0 +1,165 @@
+package db
+
+import (
+	"art/internal/models"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TestMemoryGalleryStateFacets pins the counts the $facet pipeline of
+// MongoGalleryState computes: materials are counted once per painting, and
+// paintings without a date or size have no year or orientation.
+func TestMemoryGalleryStateFacets(t *testing.T) {
+	ctx := context.Background()
+	state := NewMemoryGalleryState()
+
+	date := func(year int) primitive.DateTime {
+		return primitive.NewDateTimeFromTime(time.Date(year, time.December, 31, 23, 0, 0, 0, time.UTC))
+	}
+	oil := models.Material{ID: "oil"}
+	canvas := models.Material{ID: "canvas"}
+	acrylic := models.Material{ID: "acrylic"}
+	for _, p := range []models.Painting{
+		{Materials: []models.Material{oil, canvas, oil}, Availability: models.AvailabilityAvailable, Price: 400, Date: date(1888), Size: &models.Dimensions{Width: 73, Height: 92}},
+		{Materials: []models.Material{oil}, Availability: models.AvailabilitySold, Price: 500, Date: date(1889), Size: &models.Dimensions{Width: 100, Height: 50}},
+		{Materials: []models.Material{acrylic}, Availability: models.AvailabilityAvailable, Price: 5000},
+		{Materials: []models.Material{canvas}, Availability: models.AvailabilityAvailable, Price: 2499.99, Date: date(1888), Size: &models.Dimensions{Width: 50, Height: 50}},
+	} {
+		_, err := state.Save(ctx, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	minPrice := 1000.0
+	tests := []struct {
+		name   string
+		filter models.PaintingFilter
+		want   models.Facets
+	}{
+		{
+			name: "all",
+			want: models.Facets{
+				Materials:    []models.FacetCount{{Value: "canvas", Count: 2}, {Value: "oil", Count: 2}, {Value: "acrylic", Count: 1}},
+				Availability: []models.FacetCount{{Value: "available", Count: 3}, {Value: "sold", Count: 1}},
+				Prices:       []models.FacetCount{{Value: "0-500", Count: 1}, {Value: "500-1000", Count: 1}, {Value: "1000-2500", Count: 1}, {Value: "5000+", Count: 1}},
+				Years:        []models.FacetCount{{Value: "1888", Count: 2}, {Value: "1889", Count: 1}},
+				Orientations: []models.FacetCount{{Value: "landscape", Count: 1}, {Value: "portrait", Count: 1}, {Value: "square", Count: 1}},
+			},
+		},
+		{
+			name:   "availability",
+			filter: models.PaintingFilter{Availability: models.AvailabilityAvailable},
+			want: models.Facets{
+				Materials:    []models.FacetCount{{Value: "canvas", Count: 2}, {Value: "acrylic", Count: 1}, {Value: "oil", Count: 1}},
+				Availability: []models.FacetCount{{Value: "available", Count: 3}},
+				Prices:       []models.FacetCount{{Value: "0-500", Count: 1}, {Value: "1000-2500", Count: 1}, {Value: "5000+", Count: 1}},
+				Years:        []models.FacetCount{{Value: "1888", Count: 2}},
+				Orientations: []models.FacetCount{{Value: "portrait", Count: 1}, {Value: "square", Count: 1}},
+			},
+		},
+		{
+			name:   "material",
+			filter: models.PaintingFilter{MaterialID: "oil"},
+			want: models.Facets{
+				Materials:    []models.FacetCount{{Value: "oil", Count: 2}, {Value: "canvas", Count: 1}},
+				Availability: []models.FacetCount{{Value: "available", Count: 1}, {Value: "sold", Count: 1}},
+				Prices:       []models.FacetCount{{Value: "0-500", Count: 1}, {Value: "500-1000", Count: 1}},
+				Years:        []models.FacetCount{{Value: "1888", Count: 1}, {Value: "1889", Count: 1}},
+				Orientations: []models.FacetCount{{Value: "landscape", Count: 1}, {Value: "portrait", Count: 1}},
+			},
+		},
+		{
+			name:   "min price",
+			filter: models.PaintingFilter{MinPrice: &minPrice},
+			want: models.Facets{
+				Materials:    []models.FacetCount{{Value: "acrylic", Count: 1}, {Value: "canvas", Count: 1}},
+				Availability: []models.FacetCount{{Value: "available", Count: 2}},
+				Prices:       []models.FacetCount{{Value: "1000-2500", Count: 1}, {Value: "5000+", Count: 1}},
+				Years:        []models.FacetCount{{Value: "1888", Count: 1}},
+				Orientations: []models.FacetCount{{Value: "square", Count: 1}},
+			},
+		},
+		{
+			name:   "no match",
+			filter: models.PaintingFilter{IDs: []primitive.ObjectID{}},
+			want: models.Facets{
+				Materials:    []models.FacetCount{},
+				Availability: []models.FacetCount{},
+				Prices:       []models.FacetCount{},
+				Years:        []models.FacetCount{},
+				Orientations: []models.FacetCount{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := state.Facets(ctx, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// evalSwitch evaluates the $switch expressions of the facet pipeline on a
+// document with numeric fields.
+func evalSwitch(t *testing.T, expr bson.M, doc map[string]float64) interface{} {
+	t.Helper()
+	operand := func(v interface{}) float64 {
+		if field, ok := v.(string); ok && strings.HasPrefix(field, "$") {
+			return doc[field[1:]]
+		}
+		return v.(float64)
+	}
+
+	sw := expr["$switch"].(bson.M)
+	for _, branch := range sw["branches"].(bson.A) {
+		for op, args := range branch.(bson.M)["case"].(bson.M) {
+			a, b := operand(args.(bson.A)[0]), operand(args.(bson.A)[1])
+			var match bool
+			switch op {
+			case "$gte":
+				match = a >= b
+			case "$gt":
+				match = a > b
+			case "$lt":
+				match = a < b
+			default:
+				t.Fatalf("unexpected operator %s", op)
+			}
+			if match {
+				return branch.(bson.M)["then"]
+			}
+		}
+	}
+	return sw["default"]
+}
+
+// TestFacetExpressionsMatchModels checks that the pipeline buckets prices
+// and orientations like the models the memory state counts with.
+func TestFacetExpressionsMatchModels(t *testing.T) {
+	for _, price := range []float64{-1, 0, 499.99, 500, 999, 1000, 2500, 4999.99, 5000, 1e9} {
+		got := evalSwitch(t, priceBucketExpr(), map[string]float64{"price": price})
+		if want := models.PriceBucket(price); got != want {
+			t.Errorf("price %g: pipeline bucket %v, models %s", price, got, want)
+		}
+	}
+
+	for _, size := range []models.Dimensions{{Width: 2, Height: 1}, {Width: 1, Height: 2}, {Width: 1, Height: 1}, {Width: 40.5, Height: 40.5}} {
+		got := evalSwitch(t, orientationExpr(), map[string]float64{"size.width": size.Width, "size.height": size.Height})
+		if want := models.Orientation(models.Painting{Size: &size}); got != want {
+			t.Errorf("size %+v: pipeline orientation %v, models %s", size, got, want)
+		}
+	}
+}
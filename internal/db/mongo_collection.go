@@ -0,0 +1,96 @@
+package db
+
+import (
+	"art/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoCollection is the Mongo counterpart of memoryCollection: the CRUD of
+// entities stored one per document, mapping missing and duplicate documents
+// to ErrNotFound and ErrConflict. name is used in errors.
+type mongoCollection[T any] struct {
+	name string
+	coll *mongo.Collection
+}
+
+func newMongoCollection[T any](coll *mongo.Collection, name string) mongoCollection[T] {
+	return mongoCollection[T]{name: name, coll: coll}
+}
+
+// find returns the documents matching filter in the order of sort.
+func (c mongoCollection[T]) find(ctx context.Context, filter bson.M, sort bson.D) ([]T, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	cursor, err := c.coll.Find(ctx, filter, options.Find().SetSort(sort))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	docs := []T{}
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+func (c mongoCollection[T]) insert(ctx context.Context, id primitive.ObjectID, doc T) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	_, err := c.coll.InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("%s %s: %w", c.name, id.Hex(), models.ErrConflict)
+	}
+	return err
+}
+
+func (c mongoCollection[T]) one(ctx context.Context, id primitive.ObjectID) (T, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	var doc T
+	err := c.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return doc, fmt.Errorf("%s %s: %w", c.name, id.Hex(), models.ErrNotFound)
+	}
+	return doc, err
+}
+
+func (c mongoCollection[T]) delete(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	res, err := c.coll.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("%s %s: %w", c.name, id.Hex(), models.ErrNotFound)
+	}
+	return nil
+}
+
+func (c mongoCollection[T]) update(ctx context.Context, id primitive.ObjectID, update bson.M) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	res, err := c.coll.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%s %s: %w", c.name, id.Hex(), models.ErrNotFound)
+	}
+	return nil
+}
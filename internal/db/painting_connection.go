@@ -3,6 +3,8 @@ package db
 import (
 	"art/internal/models"
 	"context"
+	"errors"
+	"fmt"
 	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -10,6 +12,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -40,84 +43,463 @@ func NewMongoConnection() *mongo.Database {
 	return DB
 }
 func NewMongoGalleryState() *MongoGalleryState {
-	return &MongoGalleryState{
+	state := &MongoGalleryState{
 		NewMongoConnection(),
 	}
+
+	err := state.EnsureSearchIndex(context.Background())
+	if err != nil {
+		log.Println(err)
+	}
+
+	return state
+}
+
+// legacySizes matches paintings whose size is an untyped [width, height,
+// depth] array, as stored before models.Dimensions existed.
+var legacySizes = bson.M{"size": bson.M{"$type": "array"}}
+
+// LegacySizes counts the paintings whose size MigrateSizes has yet to
+// convert. They cannot be read until it has.
+func (w *MongoGalleryState) LegacySizes(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	return w.DB.Collection("paintings").CountDocuments(ctx, legacySizes)
 }
 
-func (w *MongoGalleryState) List() []models.Painting {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+// MigrateSizes converts sizes stored as untyped [width, height, depth] arrays
+// into models.Dimensions. Sizes that cannot be interpreted are moved as they
+// are to legacySize, and their paintings returned so that someone can enter
+// them again. It returns the number of paintings converted.
+func (w *MongoGalleryState) MigrateSizes(ctx context.Context) (int, []primitive.ObjectID, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
 	defer cancel()
 
-	cursor, err := w.DB.Collection("paintings").Find(ctx, bson.M{})
+	paintings := w.DB.Collection("paintings")
+	cursor, err := paintings.Find(ctx, legacySizes)
 	if err != nil {
-		log.Println(err)
-		return nil
+		return 0, nil, err
 	}
 	defer cursor.Close(ctx)
 
-	var paintings []models.Painting
+	migrated := 0
+	var unreadable []primitive.ObjectID
+	for cursor.Next(ctx) {
+		var legacy struct {
+			ID   primitive.ObjectID `bson:"_id"`
+			Size []interface{}      `bson:"size"`
+		}
+		if err = cursor.Decode(&legacy); err != nil {
+			return migrated, unreadable, err
+		}
+
+		size, ok := models.DimensionsFromLegacy(legacy.Size)
+		update := bson.M{"$set": bson.M{"size": size}}
+		if !ok {
+			update = bson.M{"$rename": bson.M{"size": "legacySize"}}
+		}
+		_, err = paintings.UpdateOne(ctx, bson.M{"_id": legacy.ID}, update)
+		if err != nil {
+			return migrated, unreadable, err
+		}
+		if ok {
+			migrated++
+		} else {
+			unreadable = append(unreadable, legacy.ID)
+		}
+	}
+
+	return migrated, unreadable, cursor.Err()
+}
+
+// EnsureSearchIndex creates the text index used by Search and fills in search
+// keys for paintings stored before they existed.
+func (w *MongoGalleryState) EnsureSearchIndex(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	paintings := w.DB.Collection("paintings")
+	_, err := paintings.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "search.title", Value: "text"}, {Key: "search.description", Value: "text"}},
+		Options: options.Index().
+			SetName("search").
+			SetDefaultLanguage("none").
+			SetWeights(bson.D{{Key: "search.title", Value: 10}, {Key: "search.description", Value: 2}}),
+	})
+	if err != nil {
+		return err
+	}
+
+	cursor, err := paintings.Find(ctx, bson.M{"search": bson.M{"$exists": false}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var p models.Painting
+		if err = cursor.Decode(&p); err != nil {
+			return err
+		}
+		_, err = paintings.UpdateOne(ctx, bson.M{"_id": p.ID}, bson.M{"$set": bson.M{"search": models.NewSearchKeys(p)}})
+		if err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+func (w *MongoGalleryState) List(ctx context.Context, q models.PaintingQuery) ([]models.Painting, int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	filter := paintingFilter(q.Filter)
+
+	total, err := w.DB.Collection("paintings").CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort := bson.D{}
+	if field, descending := q.SortField(); field != "" {
+		direction := 1
+		if descending {
+			direction = -1
+		}
+		sort = append(sort, bson.E{Key: field, Value: direction})
+	}
+	sort = append(sort, bson.E{Key: "_id", Value: 1})
+
+	opts := options.Find().SetSort(sort).SetSkip(int64(q.Skip()))
+	if q.PerPage > 0 {
+		opts.SetLimit(int64(q.PerPage))
+	}
+
+	cursor, err := w.DB.Collection("paintings").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	paintings := []models.Painting{}
 	if err = cursor.All(ctx, &paintings); err != nil {
-		log.Println(err)
-		return nil
+		return nil, 0, err
+	}
+
+	return paintings, total, nil
+}
+
+// paintingFilter translates f into a Mongo query. It must stay in line with
+// models.PaintingFilter.Match.
+func paintingFilter(f models.PaintingFilter) bson.M {
+	filter := bson.M{}
+	if f.Availability != "" {
+		filter["availability"] = f.Availability
+	}
+	if f.MaterialID != "" {
+		filter["materials.id"] = f.MaterialID
+	}
+	if !f.ArtistID.IsZero() {
+		filter["artistId"] = f.ArtistID
+	}
+	if f.IDs != nil {
+		filter["_id"] = bson.M{"$in": f.IDs}
 	}
 
-	return paintings
+	price := bson.M{}
+	if f.MinPrice != nil {
+		price["$gte"] = *f.MinPrice
+	}
+	if f.MaxPrice != nil {
+		price["$lte"] = *f.MaxPrice
+	}
+	if len(price) > 0 {
+		filter["price"] = price
+	}
+
+	date := bson.M{}
+	if f.From != nil {
+		date["$gte"] = primitive.NewDateTimeFromTime(*f.From)
+	}
+	if f.To != nil {
+		date["$lte"] = primitive.NewDateTimeFromTime(*f.To)
+	}
+	if len(date) > 0 {
+		filter["date"] = date
+	}
+
+	rangeFilter(filter, "size.width", f.MinWidth, f.MaxWidth)
+	rangeFilter(filter, "size.height", f.MinHeight, f.MaxHeight)
+
+	return filter
 }
 
-func (w *MongoGalleryState) Save(p models.Painting) primitive.ObjectID {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+func rangeFilter(filter bson.M, field string, min, max *float64) {
+	bounds := bson.M{}
+	if min != nil {
+		bounds["$gte"] = *min
+	}
+	if max != nil {
+		bounds["$lte"] = *max
+	}
+	if len(bounds) > 0 {
+		filter[field] = bounds
+	}
+}
+
+func (w *MongoGalleryState) Save(ctx context.Context, p models.Painting) (primitive.ObjectID, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
 	defer cancel()
 
 	res, err := w.DB.Collection("paintings").InsertOne(ctx, p)
+	if mongo.IsDuplicateKeyError(err) {
+		return primitive.NilObjectID, fmt.Errorf("painting %s: %w", p.ID.Hex(), models.ErrConflict)
+	}
 	if err != nil {
-		log.Println(err)
-		return primitive.NilObjectID
+		return primitive.NilObjectID, err
 	}
 
 	id, ok := res.InsertedID.(primitive.ObjectID)
 	if !ok {
-		log.Println("InsertedID is not a valid ObjectID")
-		return primitive.NilObjectID
+		return primitive.NilObjectID, errors.New("InsertedID is not a valid ObjectID")
 	}
 
-	return id
+	return id, nil
 }
 
-func (w *MongoGalleryState) One(id primitive.ObjectID) models.Painting {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+func (w *MongoGalleryState) One(ctx context.Context, id primitive.ObjectID) (models.Painting, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
 	defer cancel()
 
 	var painting models.Painting
 	err := w.DB.Collection("paintings").FindOne(ctx, bson.M{"_id": id}).Decode(&painting)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.Painting{}, fmt.Errorf("painting %s: %w", id.Hex(), models.ErrNotFound)
+	}
 	if err != nil {
-		log.Println(err)
-		return models.Painting{}
+		return models.Painting{}, err
 	}
 
-	return painting
+	return painting, nil
 }
 
-func (w *MongoGalleryState) Delete(id primitive.ObjectID) bool {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+func (w *MongoGalleryState) Delete(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
 	defer cancel()
 
-	_, err := w.DB.Collection("paintings").DeleteOne(ctx, bson.M{"_id": id})
+	res, err := w.DB.Collection("paintings").DeleteOne(ctx, bson.M{"_id": id})
 	if err != nil {
-		log.Println(err)
-		return false
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("painting %s: %w", id.Hex(), models.ErrNotFound)
 	}
-	return true
+	return nil
 }
 
-func (w *MongoGalleryState) Update(id primitive.ObjectID, update bson.M) bool {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+func (w *MongoGalleryState) Update(ctx context.Context, id primitive.ObjectID, update bson.M) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
 	defer cancel()
 
-	_, err := w.DB.Collection("paintings").UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": update})
+	res, err := w.DB.Collection("paintings").UpdateOne(ctx, bson.M{"_id": id}, update)
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("painting %s: %w", id.Hex(), models.ErrConflict)
+	}
 	if err != nil {
-		log.Println(err)
-		return false
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("painting %s: %w", id.Hex(), models.ErrNotFound)
+	}
+	return nil
+}
+
+func (w *MongoGalleryState) UpdateIf(ctx context.Context, id primitive.ObjectID, cond bson.M, update bson.M) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": id}
+	for field, value := range cond {
+		filter[field] = value
+	}
+
+	paintings := w.DB.Collection("paintings")
+	res, err := paintings.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount > 0 {
+		return nil
+	}
+
+	exists, err := paintings.CountDocuments(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return fmt.Errorf("painting %s: %w", id.Hex(), models.ErrNotFound)
+	}
+	return fmt.Errorf("painting %s was changed concurrently: %w", id.Hex(), models.ErrConflict)
+}
+
+func (w *MongoGalleryState) UpdateMaterial(ctx context.Context, m models.Material) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"materials.$[m].en": m.EN, "materials.$[m].ukr": m.UKR}}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{"m.id": m.ID}},
+	})
+	_, err := w.DB.Collection("paintings").UpdateMany(ctx, bson.M{"materials.id": m.ID}, update, opts)
+	return err
+}
+
+func (w *MongoGalleryState) Materials(ctx context.Context) ([]models.Material, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$unwind", Value: "$materials"}},
+		{{Key: "$group", Value: bson.M{
+			"_id": "$materials.id",
+			"en":  bson.M{"$first": "$materials.en"},
+			"ukr": bson.M{"$first": "$materials.ukr"},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+	cursor, err := w.DB.Collection("paintings").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var groups []struct {
+		ID  string `bson:"_id"`
+		EN  string `bson:"en"`
+		UKR string `bson:"ukr"`
 	}
-	return true
+	if err = cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	materials := make([]models.Material, 0, len(groups))
+	for _, g := range groups {
+		materials = append(materials, models.Material{ID: g.ID, EN: g.EN, UKR: g.UKR})
+	}
+	return materials, nil
+}
+
+func (w *MongoGalleryState) Search(ctx context.Context, terms []string, limit int) ([]models.SearchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.M{"score": score})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+
+	filter := bson.M{"$text": bson.M{"$search": strings.Join(terms, " ")}}
+	cursor, err := w.DB.Collection("paintings").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	results := []models.SearchResult{}
+	for cursor.Next(ctx) {
+		var result models.SearchResult
+		if err = cursor.Decode(&result.Painting); err != nil {
+			return nil, err
+		}
+		result.Score, _ = cursor.Current.Lookup("score").DoubleOK()
+		results = append(results, result)
+	}
+
+	return results, cursor.Err()
+}
+
+func (w *MongoGalleryState) Facets(ctx context.Context, f models.PaintingFilter) (models.Facets, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	count := bson.M{"$sum": 1}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: paintingFilter(f)}},
+		{{Key: "$facet", Value: bson.M{
+			models.FacetMaterials: bson.A{
+				bson.M{"$unwind": "$materials"},
+				bson.M{"$group": bson.M{"_id": bson.M{"material": "$materials.id", "painting": "$_id"}}},
+				bson.M{"$group": bson.M{"_id": "$_id.material", "count": count}},
+			},
+			models.FacetAvailability: bson.A{
+				bson.M{"$group": bson.M{"_id": "$availability", "count": count}},
+			},
+			models.FacetPrices: bson.A{
+				bson.M{"$group": bson.M{"_id": priceBucketExpr(), "count": count}},
+			},
+			models.FacetYears: bson.A{
+				bson.M{"$match": bson.M{"date": bson.M{"$type": "date"}}},
+				bson.M{"$group": bson.M{"_id": bson.M{"$year": "$date"}, "count": count}},
+			},
+			models.FacetOrientations: bson.A{
+				bson.M{"$match": bson.M{"size.width": bson.M{"$gt": 0}, "size.height": bson.M{"$gt": 0}}},
+				bson.M{"$group": bson.M{"_id": orientationExpr(), "count": count}},
+			},
+		}}},
+	}
+
+	cursor, err := w.DB.Collection("paintings").Aggregate(ctx, pipeline)
+	if err != nil {
+		return models.Facets{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []map[string][]struct {
+		Value interface{} `bson:"_id"`
+		Count int64       `bson:"count"`
+	}
+	if err = cursor.All(ctx, &results); err != nil {
+		return models.Facets{}, err
+	}
+
+	counter := models.FacetCounter{}
+	for _, result := range results {
+		for facet, counts := range result {
+			for _, c := range counts {
+				if c.Value != nil {
+					counter.Add(facet, fmt.Sprint(c.Value), c.Count)
+				}
+			}
+		}
+	}
+
+	return counter.Facets(), nil
+}
+
+// priceBucketExpr labels a painting with its price range, see models.PriceBucket.
+func priceBucketExpr() bson.M {
+	labels := models.PriceBucketLabels()
+	branches := bson.A{}
+	for i := len(models.PriceBuckets) - 1; i > 0; i-- {
+		branches = append(branches, bson.M{
+			"case": bson.M{"$gte": bson.A{"$price", models.PriceBuckets[i]}},
+			"then": labels[i],
+		})
+	}
+	return bson.M{"$switch": bson.M{"branches": branches, "default": labels[0]}}
+}
+
+// orientationExpr derives the orientation from the stored dimensions, see
+// models.Orientation. Paintings without a size must be filtered out first.
+func orientationExpr() bson.M {
+	return bson.M{"$switch": bson.M{
+		"branches": bson.A{
+			bson.M{"case": bson.M{"$gt": bson.A{"$size.width", "$size.height"}}, "then": models.OrientationLandscape},
+			bson.M{"case": bson.M{"$lt": bson.A{"$size.width", "$size.height"}}, "then": models.OrientationPortrait},
+		},
+		"default": models.OrientationSquare,
+	}}
 }
@@ -2,28 +2,32 @@ package db
 
 import (
 	"art/internal/models"
+	"art/internal/types"
 	"context"
 	"errors"
+	"fmt"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"log"
+	"sync"
 	"time"
 )
 
 type MongoUserState struct {
 	DB            *mongo.Database
-	Authenticated map[string]bool
+	Authenticated map[string]types.Login
+	mu            sync.RWMutex
 }
 
 func NewMongoUserState(db *mongo.Database) *MongoUserState {
 	return &MongoUserState{
 		DB:            db,
-		Authenticated: make(map[string]bool),
+		Authenticated: make(map[string]types.Login),
 	}
 }
 
-func (u *MongoUserState) Register(user models.User) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+func (u *MongoUserState) Register(ctx context.Context, user models.User) error {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
 	defer cancel()
 
 	var result models.User
@@ -43,8 +47,8 @@ func (u *MongoUserState) Register(user models.User) error {
 	return nil
 }
 
-func (u *MongoUserState) Login(user models.User) (models.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+func (u *MongoUserState) Login(ctx context.Context, user models.User) (models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
 	defer cancel()
 
 	var result models.User
@@ -61,11 +65,33 @@ func (u *MongoUserState) Login(user models.User) (models.User, error) {
 	return result, nil
 }
 
-func (u *MongoUserState) SetAuthenticated(session string, authenticated bool) {
-	u.Authenticated[session] = authenticated
+func (u *MongoUserState) User(ctx context.Context, login types.Login) (models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	var result models.User
+	err := u.DB.Collection("users").FindOne(ctx, bson.M{"login": login}).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.User{}, fmt.Errorf("user %s: %w", login, models.ErrNotFound)
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return result, nil
+}
+
+func (u *MongoUserState) SetAuthenticated(ctx context.Context, session string, login types.Login) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.Authenticated[session] = login
 }
 
-func (u *MongoUserState) IsAuthenticated(session string) bool {
-	authenticated, exists := u.Authenticated[session]
-	return exists && authenticated
+func (u *MongoUserState) IsAuthenticated(ctx context.Context, session string) (types.Login, bool) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	login, exists := u.Authenticated[session]
+	return login, exists
 }
@@ -0,0 +1,125 @@
+package drive
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/drive/v3"
+)
+
+// ErrNoToken is returned when OAuth client credentials are configured but no
+// token has been provisioned for them yet.
+var ErrNoToken = errors.New("no Drive OAuth token, run `art drive-auth` to create one")
+
+// Credentials locates the files Drive clients authenticate with. File holds
+// either a service account key, which is used on its own, or an OAuth client
+// secret, which needs the token stored in TokenFile.
+type Credentials struct {
+	File      string
+	TokenFile string
+}
+
+// Client returns an HTTP client authorized for Drive. It never prompts: a
+// missing token is reported as ErrNoToken.
+func (c Credentials) Client(ctx context.Context) (*http.Client, error) {
+	b, err := os.ReadFile(c.File)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read Drive credentials: %w", err)
+	}
+
+	var key struct {
+		Type string `json:"type"`
+	}
+	err = json.Unmarshal(b, &key)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse Drive credentials %s: %w", c.File, err)
+	}
+	if key.Type == "service_account" {
+		config, err := google.JWTConfigFromJSON(b, drive.DriveScope)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse Drive service account %s: %w", c.File, err)
+		}
+		return config.Client(ctx), nil
+	}
+
+	config, err := google.ConfigFromJSON(b, drive.DriveScope)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse Drive OAuth client %s: %w", c.File, err)
+	}
+	tok, err := tokenFromFile(c.TokenFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w (looked in %s)", ErrNoToken, c.TokenFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read Drive token %s: %w", c.TokenFile, err)
+	}
+	return config.Client(ctx, tok), nil
+}
+
+// Bootstrap runs the one-time OAuth consent flow for an OAuth client secret:
+// it prints the consent URL to out, reads the authorization code from in and
+// stores the resulting token in TokenFile.
+func (c Credentials) Bootstrap(ctx context.Context, in io.Reader, out io.Writer) error {
+	b, err := os.ReadFile(c.File)
+	if err != nil {
+		return fmt.Errorf("Unable to read Drive credentials: %w", err)
+	}
+	config, err := google.ConfigFromJSON(b, drive.DriveScope)
+	if err != nil {
+		return fmt.Errorf("Unable to parse Drive OAuth client %s: %w", c.File, err)
+	}
+
+	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
+	fmt.Fprintf(out, "Go to the following link in your browser then type the "+
+		"authorization code: \n%v\n", authURL)
+
+	fmt.Fprintln(out, "Paste authorization code here:")
+	authCode, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("Unable to read authorization code: %w", err)
+	}
+	authCode = strings.TrimSpace(authCode)
+	if authCode == "" {
+		return errors.New("No authorization code given")
+	}
+
+	tok, err := config.Exchange(ctx, authCode)
+	if err != nil {
+		return fmt.Errorf("Unable to retrieve token from web: %w", err)
+	}
+
+	fmt.Fprintf(out, "Saving token to: %s\n", c.TokenFile)
+	return saveToken(c.TokenFile, tok)
+}
+
+func tokenFromFile(file string) (*oauth2.Token, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tok := &oauth2.Token{}
+	err = json.NewDecoder(f).Decode(tok)
+	return tok, err
+}
+
+func saveToken(path string, token *oauth2.Token) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("Unable to cache oauth token: %w", err)
+	}
+	err = json.NewEncoder(f).Encode(token)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,584 @@
+// Package drivetest provides an in-memory fake of the parts of the Drive API
+// used by the drive package, so that uploads, permissions and deletions can
+// be exercised offline.
+package drivetest
+
+import (
+	"art/internal/drive"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const folderMimeType = "application/vnd.google-apps.folder"
+
+// File is a file or folder stored by the fake server.
+type File struct {
+	ID       string
+	Name     string
+	MimeType string
+	Parents  []string
+	Content  []byte
+	// Public reports whether an "anyone" permission was granted.
+	Public  bool
+	Created time.Time
+}
+
+// Server is a fake Drive API. Its zero value is not usable, use NewServer.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	files    map[string]*File
+	nextID   int
+	sessions map[string]*session
+	failures []int
+	losses   []int
+	requests []string
+}
+
+// session is a resumable upload. file is the ID of the file it created once
+// complete.
+type session struct {
+	meta    fileMetadata
+	content []byte
+	file    string
+}
+
+func NewServer() *Server {
+	s := &Server{files: make(map[string]*File), sessions: make(map[string]*session)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Config returns a drive.Config pointing at the server, with root as the root
+// folder.
+func (s *Server) Config(root string) drive.Config {
+	return drive.Config{
+		HTTPClient:   s.Client(),
+		Endpoint:     s.URL + "/drive/v3/",
+		RootFolderID: root,
+	}
+}
+
+// AddFolder creates a folder and returns its ID.
+func (s *Server) AddFolder(name string, parent string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f := s.add(File{Name: name, MimeType: folderMimeType, Parents: parentList(parent)})
+	return f.ID
+}
+
+// File returns a copy of the file with the given ID.
+func (s *Server) File(id string) (File, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.files[id]
+	if !ok {
+		return File{}, false
+	}
+	return *f, true
+}
+
+// Files returns copies of all stored files in creation order.
+func (s *Server) Files() []File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files := make([]File, 0, len(s.files))
+	for _, f := range s.files {
+		files = append(files, *f)
+	}
+	sort.Slice(files, func(i, j int) bool { return idLess(files[i].ID, files[j].ID) })
+	return files
+}
+
+// Children returns copies of the files directly in the given folder.
+func (s *Server) Children(parent string) []File {
+	var children []File
+	for _, f := range s.Files() {
+		if contains(f.Parents, parent) {
+			children = append(children, f)
+		}
+	}
+	return children
+}
+
+// FailNext makes the next n requests fail with status, to exercise retries.
+func (s *Server) FailNext(n int, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, status)
+	}
+}
+
+// LoseNext makes the next n requests be handled, but answered with status
+// instead of their response, as when a response is lost on its way back.
+func (s *Server) LoseNext(n int, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		s.losses = append(s.losses, status)
+	}
+}
+
+// Requests returns the method and path of every request received, e.g.
+// "POST /upload/drive/v3/files".
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) serve(res http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, req.Method+" "+req.URL.Path)
+	status, lost := 0, 0
+	if len(s.failures) > 0 {
+		status, s.failures = s.failures[0], s.failures[1:]
+	} else if len(s.losses) > 0 {
+		lost, s.losses = s.losses[0], s.losses[1:]
+	}
+	s.mu.Unlock()
+	if status != 0 {
+		writeError(res, status, "Injected failure")
+		return
+	}
+	if lost != 0 {
+		s.route(httptest.NewRecorder(), req)
+		writeError(res, lost, "Injected failure after handling the request")
+		return
+	}
+
+	s.route(res, req)
+}
+
+func (s *Server) route(res http.ResponseWriter, req *http.Request) {
+	path := req.URL.Path
+	switch {
+	case path == "/upload/drive/v3/files" && req.Method == http.MethodPost && req.URL.Query().Has("upload_id"):
+		s.uploadChunk(res, req)
+	case path == "/upload/drive/v3/files" && req.Method == http.MethodPost && req.URL.Query().Get("uploadType") == "resumable":
+		s.startSession(res, req)
+	case path == "/upload/drive/v3/files" && req.Method == http.MethodPost:
+		s.upload(res, req)
+	case path == "/drive/v3/files" && req.Method == http.MethodGet:
+		s.list(res, req)
+	case path == "/drive/v3/files" && req.Method == http.MethodPost:
+		s.create(res, req)
+	case strings.HasPrefix(path, "/drive/v3/files/"):
+		rest := strings.TrimPrefix(path, "/drive/v3/files/")
+		id, sub, _ := strings.Cut(rest, "/")
+		switch {
+		case sub == "permissions" && req.Method == http.MethodPost:
+			s.permit(res, req, id)
+		case sub == "" && req.Method == http.MethodGet:
+			s.get(res, id)
+		case sub == "" && req.Method == http.MethodDelete:
+			s.delete(res, id)
+		default:
+			writeError(res, http.StatusNotFound, "Unknown method")
+		}
+	default:
+		writeError(res, http.StatusNotFound, "Unknown method")
+	}
+}
+
+func (s *Server) create(res http.ResponseWriter, req *http.Request) {
+	var meta fileMetadata
+	err := json.NewDecoder(req.Body).Decode(&meta)
+	if err != nil {
+		writeError(res, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	f := s.add(File{Name: meta.Name, MimeType: meta.MimeType, Parents: meta.Parents})
+	s.mu.Unlock()
+
+	s.writeFile(res, f)
+}
+
+// upload handles multipart uploads: a JSON metadata part followed by the
+// media part.
+func (s *Server) upload(res http.ResponseWriter, req *http.Request) {
+	if t := req.URL.Query().Get("uploadType"); t != "multipart" {
+		writeError(res, http.StatusBadRequest, fmt.Sprintf("unsupported uploadType %q", t))
+		return
+	}
+	_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		writeError(res, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	parts := multipart.NewReader(req.Body, params["boundary"])
+	metaPart, err := parts.NextPart()
+	if err != nil {
+		writeError(res, http.StatusBadRequest, err.Error())
+		return
+	}
+	var meta fileMetadata
+	err = json.NewDecoder(metaPart).Decode(&meta)
+	if err != nil {
+		writeError(res, http.StatusBadRequest, err.Error())
+		return
+	}
+	mediaPart, err := parts.NextPart()
+	if err != nil {
+		writeError(res, http.StatusBadRequest, err.Error())
+		return
+	}
+	content, err := io.ReadAll(mediaPart)
+	if err != nil {
+		writeError(res, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	f := s.add(File{Name: meta.Name, MimeType: meta.MimeType, Parents: meta.Parents, Content: content})
+	s.mu.Unlock()
+
+	s.writeFile(res, f)
+}
+
+// startSession starts a resumable upload. The metadata is sent now and the
+// content in chunks to the returned Location.
+func (s *Server) startSession(res http.ResponseWriter, req *http.Request) {
+	var meta fileMetadata
+	err := json.NewDecoder(req.Body).Decode(&meta)
+	if err != nil {
+		writeError(res, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	s.nextID++
+	id := fmt.Sprintf("upload%d", s.nextID)
+	s.sessions[id] = &session{meta: meta}
+	s.mu.Unlock()
+
+	res.Header().Set("Location", s.URL+"/upload/drive/v3/files?uploadType=resumable&upload_id="+id)
+	res.WriteHeader(http.StatusOK)
+}
+
+// uploadChunk receives the chunk given by the Content-Range header. Until the
+// total size is known it answers like Drive does for clients that ask not to
+// get 308 responses.
+func (s *Server) uploadChunk(res http.ResponseWriter, req *http.Request) {
+	var first, last, total int64
+	contentRange := req.Header.Get("Content-Range")
+	switch {
+	case strings.HasPrefix(contentRange, "bytes */"):
+		first, last = 0, -1
+		_, err := fmt.Sscanf(contentRange, "bytes */%d", &total)
+		if err != nil {
+			writeError(res, http.StatusBadRequest, "invalid Content-Range "+contentRange)
+			return
+		}
+	case strings.HasSuffix(contentRange, "/*"):
+		total = -1
+		_, err := fmt.Sscanf(contentRange, "bytes %d-%d/*", &first, &last)
+		if err != nil {
+			writeError(res, http.StatusBadRequest, "invalid Content-Range "+contentRange)
+			return
+		}
+	default:
+		_, err := fmt.Sscanf(contentRange, "bytes %d-%d/%d", &first, &last, &total)
+		if err != nil {
+			writeError(res, http.StatusBadRequest, "invalid Content-Range "+contentRange)
+			return
+		}
+	}
+	chunk, err := io.ReadAll(req.Body)
+	if err != nil {
+		writeError(res, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := req.URL.Query().Get("upload_id")
+	up, ok := s.sessions[id]
+	if !ok {
+		writeError(res, http.StatusNotFound, "Upload session not found: "+id)
+		return
+	}
+	if up.file != "" {
+		f, ok := s.files[up.file]
+		if !ok {
+			writeError(res, http.StatusNotFound, "File not found: "+up.file)
+			return
+		}
+		s.writeFile(res, f)
+		return
+	}
+	if first > int64(len(up.content)) || int64(len(chunk)) != last-first+1 {
+		writeError(res, http.StatusBadRequest, "chunk does not continue the upload: "+contentRange)
+		return
+	}
+	// A status query, with no chunk, leaves the upload as is. A repeated
+	// chunk overwrites what was received of it before.
+	if len(chunk) > 0 {
+		up.content = append(up.content[:first], chunk...)
+	}
+
+	if total < 0 || int64(len(up.content)) < total {
+		res.Header().Set("X-Http-Status-Code-Override", "308")
+		if len(up.content) > 0 {
+			res.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(up.content)-1))
+		}
+		res.WriteHeader(http.StatusOK)
+		return
+	}
+
+	f := s.add(File{Name: up.meta.Name, MimeType: up.meta.MimeType, Parents: up.meta.Parents, Content: up.content})
+	up.file = f.ID
+	s.writeFile(res, f)
+}
+
+func (s *Server) list(res http.ResponseWriter, req *http.Request) {
+	match, err := parseQuery(req.URL.Query().Get("q"))
+	if err != nil {
+		writeError(res, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	files := []fileMetadata{}
+	for _, f := range s.Files() {
+		f := f
+		if match(&f) {
+			files = append(files, s.metadata(&f))
+		}
+	}
+	writeJSON(res, http.StatusOK, map[string]interface{}{"files": files})
+}
+
+func (s *Server) get(res http.ResponseWriter, id string) {
+	s.mu.Lock()
+	f, ok := s.files[id]
+	s.mu.Unlock()
+	if !ok {
+		writeError(res, http.StatusNotFound, "File not found: "+id)
+		return
+	}
+	s.writeFile(res, f)
+}
+
+// delete removes a file and, like Drive, everything in it.
+func (s *Server) delete(res http.ResponseWriter, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.files[id]; !ok {
+		writeError(res, http.StatusNotFound, "File not found: "+id)
+		return
+	}
+	s.remove(id)
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) remove(id string) {
+	delete(s.files, id)
+	for childID, f := range s.files {
+		if contains(f.Parents, id) {
+			s.remove(childID)
+		}
+	}
+}
+
+func (s *Server) permit(res http.ResponseWriter, req *http.Request, id string) {
+	var perm struct {
+		Type string `json:"type"`
+		Role string `json:"role"`
+	}
+	err := json.NewDecoder(req.Body).Decode(&perm)
+	if err != nil {
+		writeError(res, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	f, ok := s.files[id]
+	if ok && perm.Type == "anyone" {
+		f.Public = true
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeError(res, http.StatusNotFound, "File not found: "+id)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, map[string]string{"id": "anyoneWithLink", "type": perm.Type, "role": perm.Role})
+}
+
+// add stores f under a new ID. s.mu must be held.
+func (s *Server) add(f File) *File {
+	s.nextID++
+	f.ID = fmt.Sprintf("file%d", s.nextID)
+	if f.Created.IsZero() {
+		f.Created = time.Now()
+	}
+	s.files[f.ID] = &f
+	return &f
+}
+
+type fileMetadata struct {
+	ID          string   `json:"id,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	MimeType    string   `json:"mimeType,omitempty"`
+	Parents     []string `json:"parents,omitempty"`
+	Size        int64    `json:"size,omitempty,string"`
+	WebViewLink string   `json:"webViewLink,omitempty"`
+	CreatedTime string   `json:"createdTime,omitempty"`
+}
+
+func (s *Server) metadata(f *File) fileMetadata {
+	return fileMetadata{
+		ID:          f.ID,
+		Name:        f.Name,
+		MimeType:    f.MimeType,
+		Parents:     f.Parents,
+		Size:        int64(len(f.Content)),
+		WebViewLink: s.URL + "/file/d/" + f.ID + "/view",
+		CreatedTime: f.Created.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+func (s *Server) writeFile(res http.ResponseWriter, f *File) {
+	writeJSON(res, http.StatusOK, s.metadata(f))
+}
+
+func writeJSON(res http.ResponseWriter, status int, v interface{}) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	json.NewEncoder(res).Encode(v)
+}
+
+func writeError(res http.ResponseWriter, status int, message string) {
+	writeJSON(res, status, map[string]interface{}{
+		"error": map[string]interface{}{"code": status, "message": message},
+	})
+}
+
+// parseQuery supports the subset of the Drive query language used by the
+// drive package: clauses joined by "and", each either field='value' for
+// name and mimeType, 'value' in parents or trashed=false.
+func parseQuery(q string) (func(*File) bool, error) {
+	var checks []func(*File) bool
+	for _, clause := range splitAnd(q) {
+		clause = strings.TrimSpace(clause)
+		switch {
+		case clause == "":
+		case clause == "trashed=false" || clause == "trashed = false":
+		case strings.HasSuffix(clause, " in parents"):
+			parent, err := unquote(strings.TrimSuffix(clause, " in parents"))
+			if err != nil {
+				return nil, err
+			}
+			checks = append(checks, func(f *File) bool { return contains(f.Parents, parent) })
+		default:
+			field, value, ok := strings.Cut(clause, "=")
+			if !ok {
+				return nil, fmt.Errorf("unsupported query clause %q", clause)
+			}
+			value, err := unquote(strings.TrimSpace(value))
+			if err != nil {
+				return nil, err
+			}
+			switch strings.TrimSpace(field) {
+			case "name":
+				checks = append(checks, func(f *File) bool { return f.Name == value })
+			case "mimeType":
+				checks = append(checks, func(f *File) bool { return f.MimeType == value })
+			default:
+				return nil, fmt.Errorf("unsupported query field %q", field)
+			}
+		}
+	}
+
+	return func(f *File) bool {
+		for _, check := range checks {
+			if !check(f) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// splitAnd splits q on " and " outside of quoted strings.
+func splitAnd(q string) []string {
+	var clauses []string
+	quoted, escaped, start := false, false, 0
+	for i := 0; i < len(q); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case q[i] == '\\':
+			escaped = true
+		case q[i] == '\'':
+			quoted = !quoted
+		case !quoted && strings.HasPrefix(q[i:], " and "):
+			clauses = append(clauses, q[start:i])
+			start = i + len(" and ")
+			i = start - 1
+		}
+	}
+	return append(clauses, q[start:])
+}
+
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '\'' || s[len(s)-1] != '\'' {
+		return "", fmt.Errorf("expected a quoted string, got %s", s)
+	}
+	s = s[1 : len(s)-1]
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String(), nil
+}
+
+func parentList(parent string) []string {
+	if parent == "" {
+		return nil
+	}
+	return []string{parent}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// idLess orders IDs by creation.
+func idLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
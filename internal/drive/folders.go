@@ -0,0 +1,73 @@
+package drive
+
+import (
+	"container/list"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// maxCachedFolders bounds the folder IDs a Client remembers. Items are
+// uploaded to one folder at a time, so only recent folders are worth
+// keeping.
+const maxCachedFolders = 1000
+
+// folderCache maps the parent and name of folders to their ID, evicting the
+// least recently used entries beyond its size. It is not safe for concurrent
+// use.
+type folderCache struct {
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type folderEntry struct {
+	key string
+	id  string
+}
+
+func newFolderCache(size int) *folderCache {
+	return &folderCache{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *folderCache) get(key string) (string, bool) {
+	e, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*folderEntry).id, true
+}
+
+func (c *folderCache) add(key string, id string) {
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*folderEntry).id = id
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&folderEntry{key: key, id: id})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*folderEntry).key)
+	}
+}
+
+// removeID forgets the folder with the given ID, whatever its name.
+func (c *folderCache) removeID(id string) {
+	for e := c.order.Front(); e != nil; {
+		next := e.Next()
+		if entry := e.Value.(*folderEntry); entry.id == id {
+			c.order.Remove(e)
+			delete(c.entries, entry.key)
+		}
+		e = next
+	}
+}
+
+// folderCall is a lookup or creation of a folder in progress, which other
+// uploads to the same folder wait for.
+type folderCall struct {
+	done chan struct{}
+	file *drive.File
+	err  error
+}
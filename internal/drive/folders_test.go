@@ -0,0 +1,36 @@
+package drive
+
+import "testing"
+
+func TestFolderCache(t *testing.T) {
+	c := newFolderCache(2)
+	c.add("root/a", "1")
+	c.add("root/b", "2")
+	if _, ok := c.get("root/a"); !ok {
+		t.Fatal("root/a not cached")
+	}
+
+	// b is the least recently used, and goes first.
+	c.add("root/c", "3")
+	if _, ok := c.get("root/b"); ok {
+		t.Error("root/b still cached past the size")
+	}
+	if id, ok := c.get("root/a"); !ok || id != "1" {
+		t.Errorf("root/a = %q, %v; want 1", id, ok)
+	}
+	if n := c.order.Len(); n != 2 {
+		t.Errorf("%d folders cached, want 2", n)
+	}
+
+	c.add("other/c", "3")
+	c.removeID("3")
+	if _, ok := c.get("root/c"); ok {
+		t.Error("root/c still cached after removing its ID")
+	}
+	if _, ok := c.get("other/c"); ok {
+		t.Error("other/c still cached after removing its ID")
+	}
+	if id, ok := c.get("root/a"); !ok || id != "1" {
+		t.Errorf("root/a = %q, %v; want 1", id, ok)
+	}
+}
@@ -1,103 +1,110 @@
 package drive
 
 import (
-	"art/internal/models"
+	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
-	"os"
-	"path/filepath"
+	"path"
+	"strings"
+	"sync"
+	"time"
 
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
 )
 
-func getDriveService() (*drive.Service, error) {
-	b, err := os.ReadFile("ServiceAccountCred.json")
-	if err != nil {
-		fmt.Printf("Unable to read ServiceAccountCred.json file. Err: %v\n", err)
-		return nil, err
-	}
+const folderMimeType = "application/vnd.google-apps.folder"
+
+// DefaultRootFolderID is the Drive folder holding one folder per gallery item.
+const DefaultRootFolderID = "1BlTd6XF81bGBXKZvspzQr8sgbPPuYOGx"
+
+// Config describes how a Client reaches Drive.
+type Config struct {
+	Credentials Credentials
+	// HTTPClient, when set, is used as is instead of authenticating with
+	// Credentials.
+	HTTPClient *http.Client
+	// Endpoint overrides the Drive API base URL, e.g. with a drivetest
+	// server.
+	Endpoint string
+	// RootFolderID is the folder item folders are created in. It defaults to
+	// DefaultRootFolderID.
+	RootFolderID string
+	// Retry defaults to DefaultRetryPolicy.
+	Retry *RetryPolicy
+	// ChunkSize is the size of the chunks files larger than it are uploaded
+	// in through a resumable upload session. It defaults to
+	// googleapi.DefaultUploadChunkSize.
+	ChunkSize int
+}
 
-	config, err := google.ConfigFromJSON(b, drive.DriveScope)
+// Client uploads images to and deletes folders from Drive. It is safe for
+// concurrent use.
+type Client struct {
+	service   *drive.Service
+	client    *http.Client
+	root      string
+	retry     RetryPolicy
+	chunkSize int
+
+	// mu guards folders, which caches the IDs of folders by parent and
+	// name, and pending, which holds the folder lookups in progress so that
+	// files uploaded in parallel to a new folder do not each create one.
+	mu      sync.Mutex
+	folders *folderCache
+	pending map[string]*folderCall
+}
 
-	if err != nil {
-		return nil, err
+func NewClient(ctx context.Context, config Config) (*Client, error) {
+	client := config.HTTPClient
+	if client == nil {
+		var err error
+		client, err = config.Credentials.Client(ctx)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	client := getClient(config)
-
-	service, err := drive.New(client)
-
-	if err != nil {
-		fmt.Printf("Cannot create the Google Drive service: %v\n", err)
-		return nil, err
+	opts := []option.ClientOption{option.WithHTTPClient(client)}
+	if config.Endpoint != "" {
+		opts = append(opts, option.WithEndpoint(config.Endpoint))
 	}
-
-	return service, err
-}
-
-func getClient(config *oauth2.Config) *http.Client {
-
-	tokFile := "token.json"
-	tok, err := tokenFromFile(tokFile)
+	service, err := drive.NewService(ctx, opts...)
 	if err != nil {
-		tok = getTokenFromWeb(config)
-		saveToken(tokFile, tok)
+		return nil, fmt.Errorf("Cannot create the Google Drive service: %w", err)
 	}
-	return config.Client(context.Background(), tok)
-}
 
-func tokenFromFile(file string) (*oauth2.Token, error) {
-	f, err := os.Open(file)
-	if err != nil {
-		return nil, err
+	root := config.RootFolderID
+	if root == "" {
+		root = DefaultRootFolderID
 	}
-	defer f.Close()
-	tok := &oauth2.Token{}
-	err = json.NewDecoder(f).Decode(tok)
-	return tok, err
-}
-
-func getTokenFromWeb(config *oauth2.Config) *oauth2.Token {
-	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	fmt.Printf("Go to the following link in your browser then type the "+
-		"authorization code: \n%v\n", authURL)
 
-	fmt.Println("Paste Authrization code here :")
-	var authCode string
-	if _, err := fmt.Scan(&authCode); err != nil {
-		log.Fatalf("Unable to read authorization code %v", err)
+	retry := DefaultRetryPolicy
+	if config.Retry != nil {
+		retry = *config.Retry
 	}
-
-	tok, err := config.Exchange(context.TODO(), authCode)
-	if err != nil {
-		log.Fatalf("Unable to retrieve token from web %v", err)
+	chunkSize := config.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = googleapi.DefaultUploadChunkSize
 	}
-	return tok
-}
 
-func saveToken(path string, token *oauth2.Token) {
-	fmt.Printf("Saving ServiceAccountCred file to: %s\n", path)
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		log.Fatalf("Unable to cache oauth token: %v", err)
-	}
-	defer f.Close()
-	json.NewEncoder(f).Encode(token)
+	return &Client{service: service, client: client, root: root, retry: retry, chunkSize: chunkSize, folders: newFolderCache(maxCachedFolders), pending: make(map[string]*folderCall)}, nil
 }
 
-func createFile(service *drive.Service, name string, mimeType string, content io.Reader, parentId string) (*drive.File, error) {
+// createFile uploads content in a single request.
+func (c *Client) createFile(ctx context.Context, name string, mimeType string, content io.Reader, parentId string) (*drive.File, error) {
 	f := &drive.File{
 		MimeType: mimeType,
 		Name:     name,
 		Parents:  []string{parentId},
 	}
-	file, err := service.Files.Create(f).Media(content).Do()
+	file, err := c.service.Files.Create(f).Media(content, googleapi.ChunkSize(0)).Context(ctx).Do()
 
 	if err != nil {
 		log.Println("Could not create file: " + err.Error())
@@ -107,25 +114,103 @@ func createFile(service *drive.Service, name string, mimeType string, content io
 	return file, nil
 }
 
-func createFolder(service *drive.Service, name string, parentId string) (*drive.File, error) {
-	q := fmt.Sprintf("mimeType='application/vnd.google-apps.folder' and name='%s' and '%s' in parents", name, parentId)
-	r, err := service.Files.List().Q(q).Do()
+// find returns the first file named name in the parent folder, or nil.
+func (c *Client) find(ctx context.Context, name string, mimeType string, parentId string) (*drive.File, error) {
+	var file *drive.File
+	err := c.retry.do(ctx, func() error {
+		var err error
+		file, err = c.lookup(ctx, name, mimeType, parentId)
+		return err
+	})
+	return file, err
+}
+
+// lookup is find without retries.
+func (c *Client) lookup(ctx context.Context, name string, mimeType string, parentId string) (*drive.File, error) {
+	q := fmt.Sprintf("name='%s' and '%s' in parents and trashed=false", escapeQuery(name), escapeQuery(parentId))
+	if mimeType != "" {
+		q = fmt.Sprintf("mimeType='%s' and %s", escapeQuery(mimeType), q)
+	}
+
+	r, err := c.service.Files.List().Q(q).Context(ctx).Do()
 	if err != nil {
-		log.Println("Could not find folder: " + err.Error())
 		return nil, err
 	}
 
 	if len(r.Files) > 0 {
 		return r.Files[0], nil
 	}
+	return nil, nil
+}
+
+// createFolder returns the folder named name in the parent folder, creating
+// it if needed. Calls for the same folder wait for the one in progress
+// instead of looking it up again; calls for other folders do not wait.
+func (c *Client) createFolder(ctx context.Context, name string, parentId string) (*drive.File, error) {
+	key := parentId + "/" + name
+	for {
+		c.mu.Lock()
+		if id, ok := c.folders.get(key); ok {
+			c.mu.Unlock()
+			return &drive.File{Id: id, Name: name, MimeType: folderMimeType, Parents: []string{parentId}}, nil
+		}
+		call, waiting := c.pending[key]
+		if !waiting {
+			call = &folderCall{done: make(chan struct{})}
+			c.pending[key] = call
+		}
+		c.mu.Unlock()
+
+		if !waiting {
+			call.file, call.err = c.findOrCreateFolder(ctx, name, parentId)
+			c.mu.Lock()
+			delete(c.pending, key)
+			if call.err == nil {
+				c.folders.add(key, call.file.Id)
+			}
+			c.mu.Unlock()
+			close(call.done)
+			return call.file, call.err
+		}
+
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		// The call waited for may have given up with its own request: try
+		// again rather than fail this one for it.
+		if call.err != nil && ctx.Err() == nil && (errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded)) {
+			continue
+		}
+		return call.file, call.err
+	}
+}
+
+// findOrCreateFolder is createFolder without the cache.
+func (c *Client) findOrCreateFolder(ctx context.Context, name string, parentId string) (*drive.File, error) {
+	existing, err := c.find(ctx, name, folderMimeType, parentId)
+	if err != nil {
+		log.Println("Could not find folder: " + err.Error())
+		return nil, err
+	}
+
+	if existing != nil {
+		return existing, nil
+	}
 
 	d := &drive.File{
 		Name:     name,
-		MimeType: "application/vnd.google-apps.folder",
+		MimeType: folderMimeType,
 		Parents:  []string{parentId},
 	}
 
-	file, err := service.Files.Create(d).Do()
+	var file *drive.File
+	err = c.retry.do(ctx, func() error {
+		var err error
+		file, err = c.service.Files.Create(d).Context(ctx).Do()
+		return err
+	})
 
 	if err != nil {
 		log.Println("Could not create dir: " + err.Error())
@@ -135,97 +220,174 @@ func createFolder(service *drive.Service, name string, parentId string) (*drive.
 	return file, nil
 }
 
-func UploadImages(id string, path string) (models.Photos, error) {
-	// Step 1: Get the Google Drive service
-	srv, err := getDriveService()
+// UploadFile uploads content as name into folder, which is created under the
+// root folder if needed, and makes it readable by anyone. It returns the IDs
+// of the folder and of the uploaded file. Failed calls are retried, so
+// content is buffered unless it is an io.ReadSeeker. Content larger than the
+// chunk size is sent through a resumable upload session.
+func (c *Client) UploadFile(ctx context.Context, folder string, name string, content io.Reader) (string, string, error) {
+	body, ok := content.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(content)
+		if err != nil {
+			return "", "", err
+		}
+		body = bytes.NewReader(b)
+	}
+	start, err := body.Seek(0, io.SeekCurrent)
 	if err != nil {
-		return models.Photos{}, err
+		return "", "", err
 	}
-
-	// Step 2: Create directory
-	dir, err := createFolder(srv, id, "1BlTd6XF81bGBXKZvspzQr8sgbPPuYOGx")
+	end, err := body.Seek(0, io.SeekEnd)
 	if err != nil {
-		return models.Photos{}, fmt.Errorf("Could not create dir: %v", err)
+		return "", "", err
 	}
 
-	// Use the ID as the folder ID
-	folderId := dir.Id
-
-	var links []string
-
-	// Get the list of files in the tmp/tmpFiles directory
-	tmpEntries, err := os.ReadDir(path)
+	dir, err := c.createFolder(ctx, folder, c.root)
 	if err != nil {
-		return models.Photos{}, err
+		return "", "", fmt.Errorf("Could not create dir: %v", err)
 	}
 
-	// Step 3: Loop over the image paths and upload each image
-	for _, tmpEntry := range tmpEntries {
-		// Skip if not a file
-		if !tmpEntry.Type().IsRegular() {
-			continue
-		}
-
-		// Open the file
-		f, err := os.Open(filepath.Join(path, tmpEntry.Name()))
-		if err != nil {
-			return models.Photos{}, err
-		}
-		defer f.Close()
-
-		// Create the file and upload
-		file, err := createFile(srv, filepath.Base(f.Name()), "application/octet-stream", f, folderId)
-		if err != nil {
-			return models.Photos{}, err
-		}
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
 
-		// After the file is uploaded, make it public
-		perm := &drive.Permission{
-			Type: "anyone",
-			Role: "reader",
-		}
+	var file *drive.File
+	if end-start > int64(c.chunkSize) {
+		file, err = c.uploadResumable(ctx, name, contentType, body, start, end-start, dir.Id)
+	} else {
+		file, err = c.upload(ctx, name, contentType, body, start, dir.Id)
+	}
+	if err != nil {
+		return "", "", err
+	}
 
-		_, err = srv.Permissions.Create(file.Id, perm).Do()
-		if err != nil {
-			return models.Photos{}, err
-		}
+	err = c.share(ctx, file.Id)
+	if err != nil {
+		return "", "", err
+	}
 
-		// Now get the file metadata, including the web view link
-		file, err = srv.Files.Get(file.Id).Fields("webViewLink").Do()
-		if err != nil {
-			return models.Photos{}, err
-		}
+	return dir.Id, file.Id, nil
+}
 
-		// Add the web view link to the array of links
-		links = append(links, file.WebViewLink)
+// FindFile looks up a file previously uploaded as name into folder. It
+// reports false when either does not exist.
+func (c *Client) FindFile(ctx context.Context, folder string, name string) (string, string, bool, error) {
+	dir, err := c.find(ctx, folder, folderMimeType, c.root)
+	if err != nil || dir == nil {
+		return "", "", false, err
+	}
+	file, err := c.find(ctx, name, "", dir.Id)
+	if err != nil || file == nil {
+		return "", "", false, err
 	}
 
-	photos := models.Photos{
-		Urls:     links,
-		FolderId: folderId,
+	// The upload may have been interrupted before the file was shared.
+	err = c.share(ctx, file.Id)
+	if err != nil {
+		return "", "", false, err
 	}
+	return dir.Id, file.Id, true, nil
+}
 
-	return photos, nil
+// share makes a file readable by anyone.
+func (c *Client) share(ctx context.Context, id string) error {
+	perm := &drive.Permission{
+		Type: "anyone",
+		Role: "reader",
+	}
+	return c.retry.do(ctx, func() error {
+		_, err := c.service.Permissions.Create(id, perm).Context(ctx).Do()
+		return err
+	})
 }
 
-func DeleteFolder(id string) error {
-	fmt.Printf("Deleting folder with ID: %s\n", id)
-	srv, err := getDriveService()
-	if err != nil {
+// FileURL returns the web view link of an uploaded file.
+func (c *Client) FileURL(ctx context.Context, id string) (string, error) {
+	var file *drive.File
+	err := c.retry.do(ctx, func() error {
+		var err error
+		file, err = c.service.Files.Get(id).Fields("webViewLink").Context(ctx).Do()
 		return err
+	})
+	if err != nil {
+		return "", err
 	}
+	return file.WebViewLink, nil
+}
 
+func (c *Client) DeleteFolder(ctx context.Context, id string) error {
 	// Check if the folder exists
-	_, err = srv.Files.Get(id).Do()
+	err := c.retry.do(ctx, func() error {
+		_, err := c.service.Files.Get(id).Context(ctx).Do()
+		return err
+	})
+	if isNotFound(err) {
+		return fmt.Errorf("Folder with ID '%s' not found: %w", id, err)
+	}
 	if err != nil {
-		return fmt.Errorf("Folder with ID '%s' not found", id)
+		return err
 	}
 
-	// Now we directly attempt to delete the folder using the provided id
-	err = srv.Files.Delete(id).Do()
-	if err != nil {
+	c.mu.Lock()
+	c.folders.removeID(id)
+	c.mu.Unlock()
+
+	// Now we directly attempt to delete the folder using the provided id. A
+	// retried delete may find the folder already gone.
+	return c.retry.do(ctx, func() error {
+		err := c.service.Files.Delete(id).Context(ctx).Do()
+		if isNotFound(err) {
+			return nil
+		}
 		return err
+	})
+}
+
+// isNotFound reports whether a Drive call failed because the file does not
+// exist.
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+// Folder is a folder in the root folder.
+type Folder struct {
+	ID      string
+	Name    string
+	Created time.Time
+}
+
+// Folders lists the folders in the root folder.
+func (c *Client) Folders(ctx context.Context) ([]Folder, error) {
+	q := fmt.Sprintf("mimeType='%s' and '%s' in parents and trashed=false", folderMimeType, escapeQuery(c.root))
+
+	var folders []Folder
+	pageToken := ""
+	for {
+		var r *drive.FileList
+		err := c.retry.do(ctx, func() error {
+			var err error
+			r, err = c.service.Files.List().Q(q).Fields("nextPageToken, files(id, name, createdTime)").PageToken(pageToken).Context(ctx).Do()
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range r.Files {
+			created, _ := time.Parse(time.RFC3339, f.CreatedTime)
+			folders = append(folders, Folder{ID: f.Id, Name: f.Name, Created: created})
+		}
+		if r.NextPageToken == "" {
+			return folders, nil
+		}
+		pageToken = r.NextPageToken
 	}
+}
 
-	return nil
+// escapeQuery escapes a value for use in a quoted Drive query string.
+func escapeQuery(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
 }
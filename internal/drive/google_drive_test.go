@@ -0,0 +1,443 @@
+package drive_test
+
+import (
+	"art/internal/drive"
+	"art/internal/drive/drivetest"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// testRetry retries quickly, so that injected failures do not slow tests.
+var testRetry = drive.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+func newTestClient(t *testing.T, config func(*drive.Config)) (*drive.Client, *drivetest.Server, string) {
+	t.Helper()
+	server := drivetest.NewServer()
+	t.Cleanup(server.Close)
+	root := server.AddFolder("root", "")
+
+	c := server.Config(root)
+	c.Retry = &testRetry
+	if config != nil {
+		config(&c)
+	}
+	client, err := drive.NewClient(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server, root
+}
+
+func TestUploadFile(t *testing.T) {
+	ctx := context.Background()
+	client, server, root := newTestClient(t, nil)
+
+	dirID, fileID, err := client.UploadFile(ctx, "painting-1", "0-large.jpg", strings.NewReader("large"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, ok := server.File(dirID)
+	if !ok || dir.Name != "painting-1" || len(dir.Parents) != 1 || dir.Parents[0] != root {
+		t.Fatalf("folder %+v is not painting-1 in the root folder", dir)
+	}
+	file, ok := server.File(fileID)
+	if !ok {
+		t.Fatal("uploaded file not stored")
+	}
+	if file.Name != "0-large.jpg" || file.MimeType != "image/jpeg" || string(file.Content) != "large" || file.Parents[0] != dirID {
+		t.Errorf("uploaded file is %+v", file)
+	}
+	if !file.Public {
+		t.Error("uploaded file was not shared")
+	}
+
+	// Another file of the item goes in the same folder.
+	dirID2, _, err := client.UploadFile(ctx, "painting-1", "0-thumb.jpg", strings.NewReader("thumb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dirID2 != dirID {
+		t.Errorf("second file went to folder %s, want %s", dirID2, dirID)
+	}
+	if n := len(server.Children(root)); n != 1 {
+		t.Errorf("%d folders in the root folder, want 1", n)
+	}
+
+	foundDir, foundFile, found, err := client.FindFile(ctx, "painting-1", "0-large.jpg")
+	if err != nil || !found || foundDir != dirID || foundFile != fileID {
+		t.Errorf("FindFile = %s, %s, %v, %v; want %s, %s", foundDir, foundFile, found, err, dirID, fileID)
+	}
+	_, _, found, err = client.FindFile(ctx, "painting-2", "0-large.jpg")
+	if err != nil || found {
+		t.Errorf("FindFile in a missing folder = %v, %v", found, err)
+	}
+}
+
+func TestUploadFileRetried(t *testing.T) {
+	ctx := context.Background()
+	client, server, _ := newTestClient(t, nil)
+
+	_, _, err := client.UploadFile(ctx, "painting-1", "0-large.jpg", strings.NewReader("large"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The folder is known by now: the next requests upload and share.
+	before := len(server.Requests())
+	server.FailNext(2, http.StatusTooManyRequests)
+	_, fileID, err := client.UploadFile(ctx, "painting-1", "0-thumb.jpg", strings.NewReader("thumb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, _ := server.File(fileID)
+	if !file.Public {
+		t.Error("file was not shared")
+	}
+	// The upload fails, then so does looking for the file it may have
+	// created, before the file is looked up, uploaded and shared.
+	if n := len(server.Requests()) - before; n != 5 {
+		t.Errorf("%d requests, want 5", n)
+	}
+}
+
+func TestDeleteFolder(t *testing.T) {
+	ctx := context.Background()
+	client, server, _ := newTestClient(t, nil)
+
+	dirID, fileID, err := client.UploadFile(ctx, "painting-1", "0-large.jpg", strings.NewReader("large"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.FailNext(1, http.StatusServiceUnavailable)
+	err = client.DeleteFolder(ctx, dirID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.File(dirID); ok {
+		t.Error("folder was not deleted")
+	}
+	if _, ok := server.File(fileID); ok {
+		t.Error("file in the folder was not deleted")
+	}
+
+	// The deleted folder is no longer cached.
+	dirID2, _, err := client.UploadFile(ctx, "painting-1", "0-large.jpg", strings.NewReader("large"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dirID2 == dirID {
+		t.Error("upload went to the deleted folder")
+	}
+}
+
+func TestDeleteFolderErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("not found", func(t *testing.T) {
+		client, _, _ := newTestClient(t, nil)
+		err := client.DeleteFolder(ctx, "missing")
+		var apiErr *googleapi.Error
+		if !errors.As(err, &apiErr) || apiErr.Code != http.StatusNotFound || !strings.Contains(err.Error(), "not found") {
+			t.Errorf("got %v, want a not found error", err)
+		}
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		client, server, _ := newTestClient(t, nil)
+		dirID := server.AddFolder("painting-1", "")
+		server.FailNext(testRetry.MaxAttempts, http.StatusInternalServerError)
+
+		err := client.DeleteFolder(ctx, dirID)
+		var apiErr *googleapi.Error
+		if !errors.As(err, &apiErr) || apiErr.Code != http.StatusInternalServerError {
+			t.Errorf("got %v, want the server error", err)
+		}
+		if strings.Contains(err.Error(), "not found") {
+			t.Errorf("server error reported as %v", err)
+		}
+		if _, ok := server.File(dirID); !ok {
+			t.Error("folder was deleted")
+		}
+	})
+}
+
+func TestUploadFileLostResponse(t *testing.T) {
+	ctx := context.Background()
+	client, server, _ := newTestClient(t, nil)
+
+	dirID, _, err := client.UploadFile(ctx, "painting-1", "0-large.jpg", strings.NewReader("large"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Drive creates the file, but its response does not arrive.
+	server.LoseNext(1, http.StatusBadGateway)
+	_, fileID, err := client.UploadFile(ctx, "painting-1", "0-thumb.jpg", strings.NewReader("thumb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var thumbs []drivetest.File
+	for _, f := range server.Children(dirID) {
+		if f.Name == "0-thumb.jpg" {
+			thumbs = append(thumbs, f)
+		}
+	}
+	if len(thumbs) != 1 {
+		t.Fatalf("%d copies of the file, want 1", len(thumbs))
+	}
+	if thumbs[0].ID != fileID || !thumbs[0].Public {
+		t.Errorf("got file %s, public %v; want %s shared", fileID, thumbs[0].Public, thumbs[0].ID)
+	}
+}
+
+// chunkTransport records the Content-Range of upload session requests, and
+// makes the chunk numbered fail fail: before it reaches the server, or after
+// it was handled if lose is set.
+type chunkTransport struct {
+	base   http.RoundTripper
+	fail   int
+	lose   bool
+	chunks int
+	ranges []string
+}
+
+func (t *chunkTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	contentRange := req.Header.Get("Content-Range")
+	if contentRange == "" {
+		return t.base.RoundTrip(req)
+	}
+	t.ranges = append(t.ranges, contentRange)
+	if strings.HasPrefix(contentRange, "bytes */") {
+		return t.base.RoundTrip(req)
+	}
+
+	t.chunks++
+	if t.chunks != t.fail {
+		return t.base.RoundTrip(req)
+	}
+	if t.lose {
+		res, err := t.base.RoundTrip(req)
+		if err == nil {
+			res.Body.Close()
+		}
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+func TestUploadFileResumesSession(t *testing.T) {
+	tests := []struct {
+		name   string
+		fail   int
+		lose   bool
+		ranges []string
+	}{
+		{
+			name:   "no failure",
+			ranges: []string{"bytes 0-3/10", "bytes 4-7/10", "bytes 8-9/10"},
+		},
+		{
+			name:   "chunk failed",
+			fail:   2,
+			ranges: []string{"bytes 0-3/10", "bytes 4-7/10", "bytes */10", "bytes 4-7/10", "bytes 8-9/10"},
+		},
+		{
+			name:   "chunk response lost",
+			fail:   2,
+			lose:   true,
+			ranges: []string{"bytes 0-3/10", "bytes 4-7/10", "bytes */10", "bytes 8-9/10"},
+		},
+		{
+			name:   "last chunk response lost",
+			fail:   3,
+			lose:   true,
+			ranges: []string{"bytes 0-3/10", "bytes 4-7/10", "bytes 8-9/10", "bytes */10"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &chunkTransport{fail: tt.fail, lose: tt.lose}
+			client, server, _ := newTestClient(t, func(c *drive.Config) {
+				transport.base = c.HTTPClient.Transport
+				c.HTTPClient = &http.Client{Transport: transport}
+				c.ChunkSize = 4
+			})
+
+			dirID, fileID, err := client.UploadFile(context.Background(), "painting-1", "0-large.jpg", strings.NewReader("0123456789"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(transport.ranges, ", "); got != strings.Join(tt.ranges, ", ") {
+				t.Errorf("sent %s, want %s", got, strings.Join(tt.ranges, ", "))
+			}
+			files := server.Children(dirID)
+			if len(files) != 1 || files[0].ID != fileID {
+				t.Fatalf("folder holds %+v, want only %s", files, fileID)
+			}
+			if string(files[0].Content) != "0123456789" || files[0].MimeType != "image/jpeg" || !files[0].Public {
+				t.Errorf("uploaded file is %+v", files[0])
+			}
+		})
+	}
+}
+
+func TestRetryClassification(t *testing.T) {
+	tests := []struct {
+		status   int
+		requests int
+		ok       bool
+	}{
+		{status: http.StatusTooManyRequests, requests: 3, ok: true},
+		{status: http.StatusServiceUnavailable, requests: 3, ok: true},
+		{status: http.StatusInternalServerError, requests: 3, ok: true},
+		{status: http.StatusBadRequest, requests: 1},
+		{status: http.StatusUnauthorized, requests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			client, server, _ := newTestClient(t, nil)
+			server.FailNext(2, tt.status)
+
+			_, err := client.FileURL(context.Background(), "missing")
+			var apiErr *googleapi.Error
+			if tt.ok {
+				// Retried past the failures, the file is reported missing.
+				if !errors.As(err, &apiErr) || apiErr.Code != http.StatusNotFound {
+					t.Errorf("got %v, want the file not found", err)
+				}
+			} else if !errors.As(err, &apiErr) || apiErr.Code != tt.status {
+				t.Errorf("got %v, want status %d", err, tt.status)
+			}
+			if n := len(server.Requests()); n != tt.requests {
+				t.Errorf("%d requests, want %d", n, tt.requests)
+			}
+		})
+	}
+}
+
+// stuckTransport answers every chunk of an upload session as incomplete,
+// reporting the Range received instead of passing the chunk on.
+type stuckTransport struct {
+	base     http.RoundTripper
+	received string
+	chunks   int
+}
+
+func (t *stuckTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Content-Range") == "" {
+		return t.base.RoundTrip(req)
+	}
+	t.chunks++
+	header := http.Header{"X-Http-Status-Code-Override": {"308"}}
+	if t.received != "" {
+		header.Set("Range", t.received)
+	}
+	return &http.Response{StatusCode: http.StatusOK, Header: header, Body: http.NoBody, Request: req}, nil
+}
+
+func TestUploadFileStuckSession(t *testing.T) {
+	tests := []struct {
+		name     string
+		received string
+		chunks   int
+		err      string
+	}{
+		{name: "nothing received", chunks: 1, err: "stopped receiving"},
+		{name: "received part", received: "bytes=0-3", chunks: 2, err: "stopped receiving"},
+		{name: "received all", received: "bytes=0-9", chunks: 1, err: "without creating"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &stuckTransport{received: tt.received}
+			client, _, _ := newTestClient(t, func(c *drive.Config) {
+				transport.base = c.HTTPClient.Transport
+				c.HTTPClient = &http.Client{Transport: transport}
+				c.ChunkSize = 4
+			})
+
+			_, _, err := client.UploadFile(context.Background(), "painting-1", "0-large.jpg", strings.NewReader("0123456789"))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got %v, want an error containing %q", err, tt.err)
+			}
+			if transport.chunks != tt.chunks {
+				t.Errorf("%d chunks sent, want %d", transport.chunks, tt.chunks)
+			}
+		})
+	}
+}
+
+// blockingTransport holds the requests that mention name until release is
+// closed, telling blocked about the first one.
+type blockingTransport struct {
+	base    http.RoundTripper
+	name    string
+	blocked chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (t *blockingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.Contains(req.URL.RawQuery, t.name) {
+		t.once.Do(func() { close(t.blocked) })
+		<-t.release
+	}
+	return t.base.RoundTrip(req)
+}
+
+func TestUploadFileFoldersInParallel(t *testing.T) {
+	transport := &blockingTransport{name: "painting-slow", blocked: make(chan struct{}), release: make(chan struct{})}
+	client, server, root := newTestClient(t, func(c *drive.Config) {
+		transport.base = c.HTTPClient.Transport
+		c.HTTPClient = &http.Client{Transport: transport}
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	upload := func(name string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := client.UploadFile(context.Background(), "painting-slow", name, strings.NewReader("slow"))
+			errs <- err
+		}()
+	}
+	upload("0-large.jpg")
+	<-transport.blocked
+	for _, name := range []string{"0-thumb.jpg", "1-large.jpg", "1-thumb.jpg"} {
+		upload(name)
+	}
+
+	// A slow folder lookup does not hold up uploads to other folders.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _, err := client.UploadFile(ctx, "painting-fast", "0-large.jpg", strings.NewReader("fast"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	close(transport.release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	// The uploads to the slow folder waited for it rather than creating
+	// their own.
+	if n := len(server.Children(root)); n != 2 {
+		t.Errorf("%d folders in the root folder, want 2", n)
+	}
+}
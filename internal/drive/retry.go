@@ -0,0 +1,96 @@
+package drive
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// RetryPolicy controls how Drive calls failing with a retriable error, a
+// rate limit or server error, are repeated. Delays grow exponentially from
+// BaseDelay up to MaxDelay, with full jitter.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// delay returns how long to wait before the attempt following the given
+// zero-based attempt.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	ceiling := p.MaxDelay
+	if shifted := p.BaseDelay << attempt; shifted > 0 && shifted < ceiling {
+		ceiling = shifted
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// do calls fn until it succeeds, fails with an error that is not retriable,
+// the attempts run out or ctx is done.
+func (p RetryPolicy) do(ctx context.Context, fn func() error) error {
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(p.delay(attempt - 1))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return errors.Join(err, ctx.Err())
+			case <-timer.C:
+			}
+		}
+
+		err = fn()
+		if err == nil || !retriable(err) || ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// retriable reports whether a failed Drive call may succeed when repeated.
+func retriable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.Code == http.StatusTooManyRequests, apiErr.Code == http.StatusRequestTimeout:
+			return true
+		case apiErr.Code >= 500:
+			return true
+		case apiErr.Code == http.StatusForbidden:
+			for _, item := range apiErr.Errors {
+				if item.Reason == "rateLimitExceeded" || item.Reason == "userRateLimitExceeded" {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
+}
@@ -0,0 +1,139 @@
+package drive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for attempt, ceiling := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		seen := make(map[time.Duration]bool)
+		for i := 0; i < 200; i++ {
+			d := p.delay(attempt)
+			if d < 0 || d > ceiling {
+				t.Fatalf("delay(%d) = %v, want within [0, %v]", attempt, d, ceiling)
+			}
+			seen[d] = true
+		}
+		// Full jitter spreads the delays rather than always waiting as long.
+		if len(seen) < 10 {
+			t.Errorf("delay(%d) took only %d values in 200 draws", attempt, len(seen))
+		}
+	}
+
+	// Shifting past the width of a Duration must not undo the cap.
+	if d := p.delay(70); d < 0 || d > time.Second {
+		t.Errorf("delay(70) = %v, want within [0, 1s]", d)
+	}
+	if d := (RetryPolicy{}).delay(3); d != 0 {
+		t.Errorf("delay without delays = %v, want 0", d)
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	retriableErr := &googleapi.Error{Code: http.StatusServiceUnavailable}
+	permanentErr := &googleapi.Error{Code: http.StatusBadRequest}
+	p := RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	tests := []struct {
+		name  string
+		errs  []error
+		calls int
+		err   error
+	}{
+		{name: "success", errs: []error{nil}, calls: 1},
+		{name: "recovers", errs: []error{retriableErr, retriableErr, nil}, calls: 3},
+		{name: "permanent", errs: []error{permanentErr, nil}, calls: 1, err: permanentErr},
+		{name: "attempts run out", errs: []error{retriableErr, retriableErr, retriableErr, retriableErr, nil}, calls: 4, err: retriableErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := p.do(context.Background(), func() error {
+				calls++
+				return tt.errs[calls-1]
+			})
+			if err != tt.err {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+			if calls != tt.calls {
+				t.Errorf("%d calls, want %d", calls, tt.calls)
+			}
+		})
+	}
+
+	t.Run("zero attempts", func(t *testing.T) {
+		calls := 0
+		(RetryPolicy{}).do(context.Background(), func() error {
+			calls++
+			return retriableErr
+		})
+		if calls != 1 {
+			t.Errorf("%d calls, want 1", calls)
+		}
+	})
+
+	t.Run("cancelled while waiting", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		slow := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}
+		calls := 0
+		time.AfterFunc(10*time.Millisecond, cancel)
+		err := slow.do(ctx, func() error {
+			calls++
+			return retriableErr
+		})
+		if !errors.Is(err, context.Canceled) || !errors.Is(err, retriableErr) {
+			t.Errorf("got %v, want the last error and the cancellation", err)
+		}
+		if calls != 1 {
+			t.Errorf("%d calls, want 1", calls)
+		}
+	})
+}
+
+func TestRetriable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: &googleapi.Error{Code: http.StatusTooManyRequests}, want: true},
+		{err: &googleapi.Error{Code: http.StatusRequestTimeout}, want: true},
+		{err: &googleapi.Error{Code: http.StatusInternalServerError}, want: true},
+		{err: &googleapi.Error{Code: http.StatusBadGateway}, want: true},
+		{err: &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}}, want: true},
+		{err: &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "userRateLimitExceeded"}}}, want: true},
+		{err: &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "insufficientPermissions"}}}},
+		{err: &googleapi.Error{Code: http.StatusNotFound}},
+		{err: &googleapi.Error{Code: http.StatusBadRequest}},
+		{err: fmt.Errorf("upload: %w", &googleapi.Error{Code: http.StatusServiceUnavailable}), want: true},
+		{err: io.ErrUnexpectedEOF, want: true},
+		{err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: true},
+		{err: context.Canceled},
+		{err: fmt.Errorf("upload: %w", context.DeadlineExceeded)},
+		{err: errors.New("invalid image")},
+	}
+
+	for _, tt := range tests {
+		if got := retriable(tt.err); got != tt.want {
+			t.Errorf("retriable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package drive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+)
+
+// upload creates a file from the content of body at start in a single
+// request. Creating a file is not idempotent: a request can fail after Drive
+// created the file, e.g. when only its response is lost, so a retry first
+// looks for the file the failed attempt may have left.
+func (c *Client) upload(ctx context.Context, name string, mimeType string, body io.ReadSeeker, start int64, parentId string) (*drive.File, error) {
+	var file *drive.File
+	attempted := false
+	err := c.retry.do(ctx, func() error {
+		if attempted {
+			existing, err := c.lookup(ctx, name, "", parentId)
+			if err != nil || existing != nil {
+				file = existing
+				return err
+			}
+		}
+		attempted = true
+
+		_, err := body.Seek(start, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		file, err = c.createFile(ctx, name, mimeType, body, parentId)
+		return err
+	})
+	return file, err
+}
+
+// uploadResumable creates a file from the size bytes of body at start
+// through a resumable upload session, one chunk per request. A failed chunk
+// is resumed from what Drive received, rather than from the first byte.
+func (c *Client) uploadResumable(ctx context.Context, name string, mimeType string, body io.ReadSeeker, start int64, size int64, parentId string) (*drive.File, error) {
+	meta := &drive.File{
+		MimeType: mimeType,
+		Name:     name,
+		Parents:  []string{parentId},
+	}
+
+	// A session is only a URI to upload to, so starting another one when a
+	// response is lost creates no file.
+	var uri string
+	err := c.retry.do(ctx, func() error {
+		var err error
+		uri, err = c.startUpload(ctx, meta, size)
+		return err
+	})
+	if err != nil {
+		log.Println("Could not start upload: " + err.Error())
+		return nil, err
+	}
+
+	s := &uploadSession{client: c.client, uri: uri, body: body, start: start, size: size}
+	var file *drive.File
+	var offset int64
+	for file == nil {
+		if offset >= size {
+			return nil, fmt.Errorf("Drive received all %d bytes of %s without creating it", size, name)
+		}
+		previous := offset
+		failed := false
+		err = c.retry.do(ctx, func() error {
+			var err error
+			if failed {
+				file, offset, err = s.status(ctx)
+				if err != nil || file != nil {
+					return err
+				}
+			}
+			failed = true
+			file, offset, err = s.send(ctx, offset, min(int64(c.chunkSize), size-offset))
+			return err
+		})
+		if err != nil {
+			log.Println("Could not upload file: " + err.Error())
+			return nil, err
+		}
+		// Each chunk gets the whole retry budget, so a session that stopped
+		// receiving would never end.
+		if file == nil && offset <= previous {
+			return nil, fmt.Errorf("Drive stopped receiving %s at byte %d of %d", name, offset, size)
+		}
+	}
+	return file, nil
+}
+
+// startUpload starts a resumable upload session for a file of size bytes and
+// returns its URI.
+func (c *Client) startUpload(ctx context.Context, meta *drive.File, size int64) (string, error) {
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return "", err
+	}
+
+	url := googleapi.ResolveRelative(c.service.BasePath, "/upload/drive/v3/files") + "?uploadType=resumable&alt=json&prettyPrint=false"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("X-Upload-Content-Type", meta.MimeType)
+	req.Header.Set("X-Upload-Content-Length", strconv.FormatInt(size, 10))
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	err = googleapi.CheckResponse(res)
+	if err != nil {
+		return "", err
+	}
+
+	uri := res.Header.Get("Location")
+	if uri == "" {
+		return "", errors.New("Drive started an upload session without a URI")
+	}
+	return uri, nil
+}
+
+// uploadSession is a resumable upload of the size bytes of body at start.
+type uploadSession struct {
+	client *http.Client
+	uri    string
+	body   io.ReadSeeker
+	start  int64
+	size   int64
+}
+
+// send uploads n bytes from offset. It returns the file once Drive received
+// the whole content, and otherwise how much of it Drive received.
+func (s *uploadSession) send(ctx context.Context, offset int64, n int64) (*drive.File, int64, error) {
+	_, err := s.body.Seek(s.start+offset, io.SeekStart)
+	if err != nil {
+		return nil, 0, err
+	}
+	return s.do(ctx, io.LimitReader(s.body, n), n, fmt.Sprintf("bytes %d-%d/%d", offset, offset+n-1, s.size))
+}
+
+// status asks Drive how much of the content it received, like send.
+func (s *uploadSession) status(ctx context.Context) (*drive.File, int64, error) {
+	return s.do(ctx, http.NoBody, 0, fmt.Sprintf("bytes */%d", s.size))
+}
+
+func (s *uploadSession) do(ctx context.Context, body io.Reader, n int64, contentRange string) (*drive.File, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.uri, body)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.ContentLength = n
+	req.Header.Set("Content-Range", contentRange)
+	// Ask for incomplete uploads to be answered with a 200 and an override
+	// header, as the HTTP client would take a 308 for a redirect.
+	req.Header.Set("X-GUploader-No-308", "yes")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusPermanentRedirect || res.Header.Get("X-Http-Status-Code-Override") == "308" {
+		received, err := parseReceived(res.Header.Get("Range"))
+		return nil, received, err
+	}
+	err = googleapi.CheckResponse(res)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var file drive.File
+	err = json.NewDecoder(res.Body).Decode(&file)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &file, s.size, nil
+}
+
+// parseReceived returns how many bytes the Range header of an incomplete
+// upload reports, which is missing when Drive received none.
+func parseReceived(header string) (int64, error) {
+	if header == "" {
+		return 0, nil
+	}
+	last, ok := strings.CutPrefix(header, "bytes=0-")
+	if !ok {
+		return 0, fmt.Errorf("unexpected upload Range %q", header)
+	}
+	n, err := strconv.ParseInt(last, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected upload Range %q", header)
+	}
+	return n + 1, nil
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"art/internal/controllers"
 	"art/internal/types"
+	"context"
 	"errors"
 	"log"
 	"net/http"
@@ -29,9 +30,11 @@ func Authenticate(next http.HandlerFunc, userControllers *controllers.UserContro
 			return
 		}
 
+		login, _ := req.Context().Value(types.CONTEXT_LOGIN_KEY).(types.Login)
+
 		session := uuid.NewString()
 
-		userControllers.Users.SetAuthenticated(session, true)
+		userControllers.Users.SetAuthenticated(req.Context(), session, login)
 
 		cookie := http.Cookie{
 			Name:     types.SESSION_COOKIE,
@@ -65,11 +68,12 @@ func Authorize(next http.HandlerFunc, userControllers *controllers.UserControlle
 			return
 		}
 
-		if !userControllers.Users.IsAuthenticated(cookie.Value) {
+		login, ok := userControllers.Users.IsAuthenticated(req.Context(), cookie.Value)
+		if !ok {
 			http.Error(w, "Your session is expired", http.StatusUnauthorized)
 			return
 		}
 
-		next(w, req)
+		next(w, req.WithContext(context.WithValue(req.Context(), types.CONTEXT_LOGIN_KEY, login)))
 	}
 }
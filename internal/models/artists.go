@@ -0,0 +1,105 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrUnknownArtist is returned when a painting references an artist that does
+// not exist.
+var ErrUnknownArtist = errors.New("unknown artist")
+
+type Artist struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"_id"`
+	Name      string             `bson:"name,omitempty" json:"name"`
+	NameUkr   string             `bson:"nameUkr,omitempty" json:"nameUkr"`
+	Bio       string             `bson:"bio,omitempty" json:"bio"`
+	BioUkr    string             `bson:"bioUkr,omitempty" json:"bioUkr"`
+	BirthYear int                `bson:"birthYear,omitempty" json:"birthYear,omitempty"`
+	Portrait  Photos             `bson:"portrait,omitempty" json:"portrait"`
+}
+
+type ArtistState interface {
+	List(context.Context) ([]Artist, error)
+	Save(context.Context, Artist) (primitive.ObjectID, error)
+
+	One(context.Context, primitive.ObjectID) (Artist, error)
+	Delete(context.Context, primitive.ObjectID) error
+	Update(context.Context, primitive.ObjectID, bson.M) error
+}
+
+type Artists struct {
+	state   ArtistState
+	gallery GalleryState
+}
+
+func NewArtists(state ArtistState, gallery GalleryState) *Artists {
+	return &Artists{state: state, gallery: gallery}
+}
+
+func (a Artist) Validate() error {
+	if strings.TrimSpace(a.Name) == "" && strings.TrimSpace(a.NameUkr) == "" {
+		return errors.New("artist name must be present in at least one language")
+	}
+	if a.BirthYear != 0 && (a.BirthYear < 1000 || a.BirthYear > time.Now().Year()) {
+		return fmt.Errorf("birth year %d is out of range", a.BirthYear)
+	}
+	return nil
+}
+
+func (w *Artists) List(ctx context.Context) ([]Artist, error) {
+	return w.state.List(ctx)
+}
+
+func (w *Artists) Add(ctx context.Context, a Artist) (primitive.ObjectID, error) {
+	return w.state.Save(ctx, a)
+}
+
+func (w *Artists) One(ctx context.Context, id primitive.ObjectID) (Artist, error) {
+	return w.state.One(ctx, id)
+}
+
+func (w *Artists) Update(ctx context.Context, id primitive.ObjectID, update bson.M) error {
+	return w.state.Update(ctx, id, update)
+}
+
+// Works lists one page of the paintings attributed to an artist.
+func (w *Artists) Works(ctx context.Context, id primitive.ObjectID, q PaintingQuery) ([]Painting, int64, error) {
+	q.Filter.ArtistID = id
+	return w.gallery.List(ctx, q)
+}
+
+// Delete removes an artist no painting is attributed to anymore.
+func (w *Artists) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, total, err := w.Works(ctx, id, PaintingQuery{PerPage: 1})
+	if err != nil {
+		return err
+	}
+	if total > 0 {
+		return fmt.Errorf("artist %s has %d paintings: %w", id.Hex(), total, ErrConflict)
+	}
+	return w.state.Delete(ctx, id)
+}
+
+// Resolve parses the ID of an artist a painting is attributed to, rejecting
+// malformed and unknown IDs.
+func (w *Artists) Resolve(ctx context.Context, hex string) (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("%w: %q", ErrUnknownArtist, hex)
+	}
+	_, err = w.state.One(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return primitive.NilObjectID, fmt.Errorf("%w: %s", ErrUnknownArtist, hex)
+	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return id, nil
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	AvailabilityAvailable  = "available"
+	AvailabilityReserved   = "reserved"
+	AvailabilitySold       = "sold"
+	AvailabilityNotForSale = "not_for_sale"
+	AvailabilityOnLoan     = "on_loan"
+)
+
+// ErrInvalidAvailability is returned for availability values outside the
+// defined set of states.
+var ErrInvalidAvailability = errors.New("invalid availability")
+
+// availabilityTransitions lists the states each state may move to without an
+// admin override.
+var availabilityTransitions = map[string][]string{
+	AvailabilityAvailable:  {AvailabilityReserved, AvailabilitySold, AvailabilityNotForSale, AvailabilityOnLoan},
+	AvailabilityReserved:   {AvailabilityAvailable, AvailabilitySold},
+	AvailabilitySold:       {},
+	AvailabilityNotForSale: {AvailabilityAvailable, AvailabilityOnLoan},
+	AvailabilityOnLoan:     {AvailabilityAvailable, AvailabilityNotForSale},
+}
+
+// AvailabilityChange records one transition of a painting's availability.
+type AvailabilityChange struct {
+	From     string             `bson:"from,omitempty" json:"from,omitempty"`
+	To       string             `bson:"to" json:"to"`
+	Actor    string             `bson:"actor,omitempty" json:"actor,omitempty"`
+	Note     string             `bson:"note,omitempty" json:"note,omitempty"`
+	Override bool               `bson:"override,omitempty" json:"override,omitempty"`
+	At       primitive.DateTime `bson:"at" json:"at"`
+}
+
+// ValidAvailability reports whether state is one of the defined states.
+func ValidAvailability(state string) bool {
+	_, ok := availabilityTransitions[state]
+	return ok
+}
+
+// CanTransition reports whether a painting may move from one state to another
+// without an override. Paintings without a known state may take any state.
+func CanTransition(from, to string) bool {
+	if !ValidAvailability(to) {
+		return false
+	}
+	allowed, known := availabilityTransitions[from]
+	if !known {
+		return true
+	}
+	for _, state := range allowed {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+func validateTransition(from, to string, override bool) error {
+	if !ValidAvailability(to) {
+		return fmt.Errorf("%w: %q", ErrInvalidAvailability, to)
+	}
+	if from == to {
+		return fmt.Errorf("painting is already %s: %w", to, ErrConflict)
+	}
+	if !override && !CanTransition(from, to) {
+		return fmt.Errorf("cannot move painting from %s to %s without an override: %w", from, to, ErrConflict)
+	}
+	return nil
+}
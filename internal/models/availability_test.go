@@ -0,0 +1,158 @@
+package models_test
+
+import (
+	"art/internal/db"
+	"art/internal/models"
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCanTransition(t *testing.T) {
+	states := []string{
+		models.AvailabilityAvailable,
+		models.AvailabilityReserved,
+		models.AvailabilitySold,
+		models.AvailabilityNotForSale,
+		models.AvailabilityOnLoan,
+	}
+	allowed := map[[2]string]bool{
+		{models.AvailabilityAvailable, models.AvailabilityReserved}:   true,
+		{models.AvailabilityAvailable, models.AvailabilitySold}:       true,
+		{models.AvailabilityAvailable, models.AvailabilityNotForSale}: true,
+		{models.AvailabilityAvailable, models.AvailabilityOnLoan}:     true,
+		{models.AvailabilityReserved, models.AvailabilityAvailable}:   true,
+		{models.AvailabilityReserved, models.AvailabilitySold}:        true,
+		{models.AvailabilityNotForSale, models.AvailabilityAvailable}: true,
+		{models.AvailabilityNotForSale, models.AvailabilityOnLoan}:    true,
+		{models.AvailabilityOnLoan, models.AvailabilityAvailable}:     true,
+		{models.AvailabilityOnLoan, models.AvailabilityNotForSale}:    true,
+	}
+
+	for _, from := range states {
+		for _, to := range states {
+			if got := models.CanTransition(from, to); got != allowed[[2]string{from, to}] {
+				t.Errorf("CanTransition(%s, %s) = %v", from, to, got)
+			}
+		}
+		if models.CanTransition(from, "lost") {
+			t.Errorf("CanTransition(%s, lost) = true", from)
+		}
+	}
+
+	// Paintings stored before availability existed may take any state.
+	for _, to := range states {
+		if !models.CanTransition("", to) {
+			t.Errorf("CanTransition(\"\", %s) = false", to)
+		}
+	}
+	if models.CanTransition("", "lost") {
+		t.Error("CanTransition(\"\", lost) = true")
+	}
+}
+
+// staleState reads a painting as it was before another request moved it.
+type staleState struct {
+	models.GalleryState
+	stale models.Painting
+}
+
+func (s staleState) One(ctx context.Context, id primitive.ObjectID) (models.Painting, error) {
+	return s.stale, nil
+}
+
+func TestSetAvailability(t *testing.T) {
+	ctx := context.Background()
+	state := db.NewMemoryGalleryState()
+	gallery := models.NewGallery(state)
+
+	id, err := gallery.AddProduct(ctx, models.Painting{Title: "Dawn", Availability: models.AvailabilityAvailable})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		change models.AvailabilityChange
+		err    error
+	}{
+		{change: models.AvailabilityChange{To: models.AvailabilityReserved, Actor: "ann"}},
+		{change: models.AvailabilityChange{To: models.AvailabilityReserved}, err: models.ErrConflict},
+		{change: models.AvailabilityChange{To: models.AvailabilityOnLoan}, err: models.ErrConflict},
+		{change: models.AvailabilityChange{To: "lost"}, err: models.ErrInvalidAvailability},
+		{change: models.AvailabilityChange{To: models.AvailabilitySold, Note: "paid"}},
+		{change: models.AvailabilityChange{To: models.AvailabilityAvailable}, err: models.ErrConflict},
+		{change: models.AvailabilityChange{To: models.AvailabilityAvailable, Actor: "admin", Override: true}},
+	}
+	for _, step := range steps {
+		got, err := gallery.SetAvailability(ctx, id, step.change)
+		if !errors.Is(err, step.err) {
+			t.Fatalf("move to %s: got %v, want %v", step.change.To, err, step.err)
+		}
+		if err == nil && (got.To != step.change.To || got.At == 0) {
+			t.Errorf("move to %s recorded %+v", step.change.To, got)
+		}
+	}
+
+	history, err := gallery.AvailabilityHistory(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.AvailabilityChange{
+		{To: models.AvailabilityAvailable},
+		{From: models.AvailabilityAvailable, To: models.AvailabilityReserved, Actor: "ann"},
+		{From: models.AvailabilityReserved, To: models.AvailabilitySold, Note: "paid"},
+		{From: models.AvailabilitySold, To: models.AvailabilityAvailable, Actor: "admin", Override: true},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("history %+v, want %d changes", history, len(want))
+	}
+	for i, change := range history {
+		change.At = 0
+		if change != want[i] {
+			t.Errorf("change %d is %+v, want %+v", i, change, want[i])
+		}
+	}
+
+	// A painting another request moved meanwhile is not changed.
+	painting, err := gallery.GetOnePainting(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := painting
+	stale.Availability = models.AvailabilityReserved
+	_, err = models.NewGallery(staleState{GalleryState: state, stale: stale}).SetAvailability(ctx, id, models.AvailabilityChange{To: models.AvailabilitySold})
+	if !errors.Is(err, models.ErrConflict) {
+		t.Fatalf("stale move: got %v, want %v", err, models.ErrConflict)
+	}
+	history, err = gallery.AvailabilityHistory(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != len(want) {
+		t.Errorf("failed moves were recorded: %+v", history)
+	}
+}
+
+func TestSetAvailabilityWithoutState(t *testing.T) {
+	ctx := context.Background()
+	gallery := models.NewGallery(db.NewMemoryGalleryState())
+
+	// Paintings stored before availability existed have none.
+	id, err := gallery.AddProduct(ctx, models.Painting{Title: "Dawn"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = gallery.SetAvailability(ctx, id, models.AvailabilityChange{To: models.AvailabilitySold})
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := gallery.AvailabilityHistory(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].From != "" || history[0].To != models.AvailabilitySold {
+		t.Errorf("history %+v, want only the move to sold", history)
+	}
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	CollectionSeries     = "series"
+	CollectionExhibition = "exhibition"
+	CollectionCurated    = "curated"
+)
+
+// ErrUnknownPainting is returned when a collection references a painting that
+// does not exist.
+var ErrUnknownPainting = errors.New("unknown painting")
+
+// Collection groups paintings in a fixed order. Venue and dates only apply to
+// exhibitions.
+type Collection struct {
+	ID             primitive.ObjectID   `bson:"_id,omitempty" json:"_id"`
+	Kind           string               `bson:"kind" json:"kind"`
+	Title          string               `bson:"title,omitempty" json:"title"`
+	TitleUkr       string               `bson:"titleUkr,omitempty" json:"titleUkr"`
+	Description    string               `bson:"description,omitempty" json:"description"`
+	DescriptionUkr string               `bson:"descriptionUkr,omitempty" json:"descriptionUkr"`
+	Venue          string               `bson:"venue,omitempty" json:"venue,omitempty"`
+	VenueUkr       string               `bson:"venueUkr,omitempty" json:"venueUkr,omitempty"`
+	StartDate      *primitive.DateTime  `bson:"startDate,omitempty" json:"startDate,omitempty"`
+	EndDate        *primitive.DateTime  `bson:"endDate,omitempty" json:"endDate,omitempty"`
+	Cover          Photos               `bson:"cover,omitempty" json:"cover"`
+	PaintingIDs    []primitive.ObjectID `bson:"paintings" json:"paintings"`
+}
+
+type CollectionState interface {
+	List(context.Context) ([]Collection, error)
+	Save(context.Context, Collection) (primitive.ObjectID, error)
+
+	One(context.Context, primitive.ObjectID) (Collection, error)
+	Delete(context.Context, primitive.ObjectID) error
+	Update(context.Context, primitive.ObjectID, bson.M) error
+}
+
+type Collections struct {
+	state   CollectionState
+	gallery GalleryState
+}
+
+func NewCollections(state CollectionState, gallery GalleryState) *Collections {
+	return &Collections{state: state, gallery: gallery}
+}
+
+func (c Collection) Validate() error {
+	switch c.Kind {
+	case CollectionSeries, CollectionExhibition, CollectionCurated:
+	default:
+		return fmt.Errorf("collection kind %q must be one of %s, %s or %s", c.Kind, CollectionSeries, CollectionExhibition, CollectionCurated)
+	}
+	if strings.TrimSpace(c.Title) == "" && strings.TrimSpace(c.TitleUkr) == "" {
+		return errors.New("collection title must be present in at least one language")
+	}
+	if c.Kind == CollectionExhibition {
+		if strings.TrimSpace(c.Venue) == "" && strings.TrimSpace(c.VenueUkr) == "" {
+			return errors.New("exhibition venue must be present in at least one language")
+		}
+		if c.StartDate == nil {
+			return errors.New("exhibition start date must be present")
+		}
+	}
+	if c.StartDate != nil && c.EndDate != nil && *c.EndDate < *c.StartDate {
+		return errors.New("collection end date must not be before its start date")
+	}
+
+	seen := make(map[primitive.ObjectID]bool, len(c.PaintingIDs))
+	for _, id := range c.PaintingIDs {
+		if seen[id] {
+			return fmt.Errorf("painting %s is listed more than once", id.Hex())
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+func (w *Collections) List(ctx context.Context) ([]Collection, error) {
+	return w.state.List(ctx)
+}
+
+func (w *Collections) One(ctx context.Context, id primitive.ObjectID) (Collection, error) {
+	return w.state.One(ctx, id)
+}
+
+func (w *Collections) Add(ctx context.Context, c Collection) (primitive.ObjectID, error) {
+	err := w.checkPaintings(ctx, c.PaintingIDs)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return w.state.Save(ctx, c)
+}
+
+func (w *Collections) Update(ctx context.Context, id primitive.ObjectID, update bson.M) error {
+	if set, ok := update["$set"].(bson.M); ok {
+		if ids, ok := set["paintings"].([]primitive.ObjectID); ok {
+			err := w.checkPaintings(ctx, ids)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return w.state.Update(ctx, id, update)
+}
+
+func (w *Collections) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return w.state.Delete(ctx, id)
+}
+
+// Paintings returns the paintings of a collection in collection order.
+// Paintings deleted since they were added are skipped.
+func (w *Collections) Paintings(ctx context.Context, c Collection) ([]Painting, error) {
+	if len(c.PaintingIDs) == 0 {
+		return []Painting{}, nil
+	}
+	paintings, _, err := w.gallery.List(ctx, PaintingQuery{Filter: PaintingFilter{IDs: c.PaintingIDs}})
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[primitive.ObjectID]Painting, len(paintings))
+	for _, p := range paintings {
+		byID[p.ID] = p
+	}
+	ordered := make([]Painting, 0, len(paintings))
+	for _, id := range c.PaintingIDs {
+		if p, ok := byID[id]; ok {
+			ordered = append(ordered, p)
+		}
+	}
+	return ordered, nil
+}
+
+// Scope restricts f to the paintings of the collection it names, if any.
+func (w *Collections) Scope(ctx context.Context, f PaintingFilter) (PaintingFilter, error) {
+	if f.CollectionID.IsZero() {
+		return f, nil
+	}
+	c, err := w.state.One(ctx, f.CollectionID)
+	if err != nil {
+		return f, err
+	}
+	f.IDs = append([]primitive.ObjectID{}, c.PaintingIDs...)
+	return f, nil
+}
+
+func (w *Collections) checkPaintings(ctx context.Context, ids []primitive.ObjectID) error {
+	var unknown []string
+	for _, id := range ids {
+		_, err := w.gallery.One(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			unknown = append(unknown, id.Hex())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(unknown) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownPainting, strings.Join(unknown, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	UnitCentimeters = "cm"
+	UnitInches      = "in"
+
+	centimetersPerInch = 2.54
+)
+
+// Dimensions is the physical size of a painting. Stored dimensions are always
+// in centimeters; other units are converted on the way in and out.
+type Dimensions struct {
+	Width  float64 `bson:"width" json:"width"`
+	Height float64 `bson:"height" json:"height"`
+	Depth  float64 `bson:"depth,omitempty" json:"depth,omitempty"`
+	Unit   string  `bson:"unit" json:"unit"`
+}
+
+// ValidUnit reports whether unit is one of the supported length units.
+func ValidUnit(unit string) bool {
+	return unit == UnitCentimeters || unit == UnitInches
+}
+
+// Validate checks that d describes a real object. An empty unit means
+// centimeters.
+func (d Dimensions) Validate() error {
+	if d.Unit != "" && !ValidUnit(d.Unit) {
+		return fmt.Errorf("unit must be %q or %q, got %q", UnitCentimeters, UnitInches, d.Unit)
+	}
+	if d.Width <= 0 || d.Height <= 0 {
+		return fmt.Errorf("width and height must be positive")
+	}
+	if d.Depth < 0 {
+		return fmt.Errorf("depth must not be negative")
+	}
+	return nil
+}
+
+// In converts d to unit.
+func (d Dimensions) In(unit string) Dimensions {
+	from := d.Unit
+	if from == "" {
+		from = UnitCentimeters
+	}
+	if from == unit {
+		d.Unit = unit
+		return d
+	}
+
+	factor := centimetersPerInch
+	if unit == UnitInches {
+		factor = 1 / centimetersPerInch
+	}
+	return Dimensions{
+		Width:  round(d.Width * factor),
+		Height: round(d.Height * factor),
+		Depth:  round(d.Depth * factor),
+		Unit:   unit,
+	}
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// ParseLength parses a length such as "100", "100cm" or "40in" into
+// centimeters. A bare number is taken as centimeters.
+func ParseLength(s string) (float64, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	unit := UnitCentimeters
+	for _, u := range []string{UnitCentimeters, UnitInches} {
+		if strings.HasSuffix(s, u) {
+			unit = u
+			s = strings.TrimSpace(strings.TrimSuffix(s, u))
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if unit == UnitInches {
+		value *= centimetersPerInch
+	}
+	return value, nil
+}
+
+// DimensionsFromLegacy converts the untyped [width, height, depth] size arrays
+// stored before Dimensions existed. Values are assumed to be centimeters.
+func DimensionsFromLegacy(size []interface{}) (*Dimensions, bool) {
+	if len(size) < 2 {
+		return nil, false
+	}
+
+	values := make([]float64, 0, 3)
+	for _, v := range size[:min(len(size), 3)] {
+		f, ok := sizeValue(v)
+		if !ok {
+			return nil, false
+		}
+		values = append(values, f)
+	}
+
+	d := &Dimensions{Width: values[0], Height: values[1], Unit: UnitCentimeters}
+	if len(values) == 3 {
+		d.Depth = values[2]
+	}
+	if d.Validate() != nil {
+		return nil, false
+	}
+	return d, true
+}
+
+func sizeValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case string:
+		f, err := ParseLength(n)
+		return f, err == nil
+	}
+	return 0, false
+}
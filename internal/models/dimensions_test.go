@@ -0,0 +1,122 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func nearDimensions(a, b Dimensions) bool {
+	return near(a.Width, b.Width) && near(a.Height, b.Height) && near(a.Depth, b.Depth) && a.Unit == b.Unit
+}
+
+func TestParseLength(t *testing.T) {
+	tests := []struct {
+		s    string
+		want float64
+		ok   bool
+	}{
+		{s: "100", want: 100, ok: true},
+		{s: "100cm", want: 100, ok: true},
+		{s: "12.5 cm", want: 12.5, ok: true},
+		{s: "40in", want: 101.6, ok: true},
+		{s: " 40 IN ", want: 101.6, ok: true},
+		{s: "40ft"},
+		{s: "40 inches"},
+		{s: "cm"},
+		{s: ""},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLength(tt.s)
+		if tt.ok && (err != nil || !near(got, tt.want)) {
+			t.Errorf("ParseLength(%q) = %g, %v; want %g", tt.s, got, err, tt.want)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("ParseLength(%q) = %g, want an error", tt.s, got)
+		}
+	}
+}
+
+func TestDimensionsIn(t *testing.T) {
+	tests := []struct {
+		d    Dimensions
+		unit string
+		want Dimensions
+	}{
+		{d: Dimensions{Width: 100, Height: 50, Unit: UnitCentimeters}, unit: UnitInches, want: Dimensions{Width: 39.37, Height: 19.69, Unit: UnitInches}},
+		{d: Dimensions{Width: 40, Height: 30, Depth: 2, Unit: UnitInches}, unit: UnitCentimeters, want: Dimensions{Width: 101.6, Height: 76.2, Depth: 5.08, Unit: UnitCentimeters}},
+		{d: Dimensions{Width: 1, Height: 1}, unit: UnitInches, want: Dimensions{Width: 0.39, Height: 0.39, Unit: UnitInches}},
+		// No unit means centimeters, which are not rounded.
+		{d: Dimensions{Width: 73.333, Height: 92}, unit: UnitCentimeters, want: Dimensions{Width: 73.333, Height: 92, Unit: UnitCentimeters}},
+		{d: Dimensions{Width: 12.345, Height: 6, Unit: UnitInches}, unit: UnitInches, want: Dimensions{Width: 12.345, Height: 6, Unit: UnitInches}},
+	}
+
+	for _, tt := range tests {
+		if got := tt.d.In(tt.unit); !nearDimensions(got, tt.want) {
+			t.Errorf("%+v.In(%s) = %+v, want %+v", tt.d, tt.unit, got, tt.want)
+		}
+	}
+}
+
+func TestDimensionsValidate(t *testing.T) {
+	tests := []struct {
+		d  Dimensions
+		ok bool
+	}{
+		{d: Dimensions{Width: 73, Height: 92}, ok: true},
+		{d: Dimensions{Width: 73, Height: 92, Depth: 3, Unit: UnitInches}, ok: true},
+		{d: Dimensions{Width: 73, Height: 92, Unit: "ft"}},
+		{d: Dimensions{Width: 0, Height: 92}},
+		{d: Dimensions{Width: 73, Height: -1}},
+		{d: Dimensions{Width: 73, Height: 92, Depth: -1}},
+	}
+
+	for _, tt := range tests {
+		if err := tt.d.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%+v.Validate() = %v", tt.d, err)
+		}
+	}
+}
+
+func TestDimensionsFromLegacy(t *testing.T) {
+	tests := []struct {
+		name string
+		size []interface{}
+		want *Dimensions
+	}{
+		{name: "numbers", size: []interface{}{73.0, 92.0, 3.0}, want: &Dimensions{Width: 73, Height: 92, Depth: 3, Unit: UnitCentimeters}},
+		{name: "no depth", size: []interface{}{73.0, 92.0}, want: &Dimensions{Width: 73, Height: 92, Unit: UnitCentimeters}},
+		{name: "integers", size: []interface{}{int32(73), int64(92)}, want: &Dimensions{Width: 73, Height: 92, Unit: UnitCentimeters}},
+		{name: "strings", size: []interface{}{"73", "92cm", "3"}, want: &Dimensions{Width: 73, Height: 92, Depth: 3, Unit: UnitCentimeters}},
+		{name: "inches", size: []interface{}{"40in", "30 in"}, want: &Dimensions{Width: 101.6, Height: 76.2, Unit: UnitCentimeters}},
+		{name: "mixed", size: []interface{}{int32(73), "92", 3.5}, want: &Dimensions{Width: 73, Height: 92, Depth: 3.5, Unit: UnitCentimeters}},
+		{name: "extra values", size: []interface{}{73.0, 92.0, 3.0, "x"}, want: &Dimensions{Width: 73, Height: 92, Depth: 3, Unit: UnitCentimeters}},
+		{name: "one value", size: []interface{}{73.0}},
+		{name: "empty"},
+		{name: "unknown unit", size: []interface{}{"73ft", "92ft"}},
+		{name: "not a number", size: []interface{}{"wide", 92.0}},
+		{name: "null", size: []interface{}{nil, 92.0}},
+		{name: "bool", size: []interface{}{true, 92.0}},
+		{name: "zero", size: []interface{}{0.0, 92.0}},
+		{name: "negative depth", size: []interface{}{73.0, 92.0, -1.0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := DimensionsFromLegacy(tt.size)
+			if tt.want == nil {
+				if ok {
+					t.Errorf("got %+v, want unreadable", got)
+				}
+				return
+			}
+			if !ok || !nearDimensions(*got, *tt.want) {
+				t.Errorf("got %+v, %v; want %+v", got, ok, tt.want)
+			}
+		})
+	}
+}
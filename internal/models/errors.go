@@ -0,0 +1,11 @@
+package models
+
+import "errors"
+
+var (
+	// ErrNotFound is returned by state implementations when no document
+	// matches the requested ID.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write clashes with an existing document.
+	ErrConflict = errors.New("conflict")
+)
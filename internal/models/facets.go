@@ -0,0 +1,146 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+const (
+	FacetMaterials    = "materials"
+	FacetAvailability = "availability"
+	FacetPrices       = "prices"
+	FacetYears        = "years"
+	FacetOrientations = "orientations"
+)
+
+const (
+	OrientationLandscape = "landscape"
+	OrientationPortrait  = "portrait"
+	OrientationSquare    = "square"
+)
+
+// PriceBuckets are the lower bounds of the price ranges counted by the prices
+// facet. The last bucket is open-ended.
+var PriceBuckets = []float64{0, 500, 1000, 2500, 5000}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Facets holds the number of paintings per value of each browsable attribute.
+type Facets struct {
+	Materials    []FacetCount `json:"materials"`
+	Availability []FacetCount `json:"availability"`
+	Prices       []FacetCount `json:"prices"`
+	Years        []FacetCount `json:"years"`
+	Orientations []FacetCount `json:"orientations"`
+}
+
+// PriceBucket returns the label of the price range holding price.
+func PriceBucket(price float64) string {
+	i := sort.Search(len(PriceBuckets), func(i int) bool { return PriceBuckets[i] > price }) - 1
+	if i < 0 {
+		i = 0
+	}
+	return priceBucketLabel(i)
+}
+
+func priceBucketLabel(i int) string {
+	if i == len(PriceBuckets)-1 {
+		return fmt.Sprintf("%g+", PriceBuckets[i])
+	}
+	return fmt.Sprintf("%g-%g", PriceBuckets[i], PriceBuckets[i+1])
+}
+
+// PriceBucketLabels lists the price facet labels in ascending order.
+func PriceBucketLabels() []string {
+	labels := make([]string, len(PriceBuckets))
+	for i := range PriceBuckets {
+		labels[i] = priceBucketLabel(i)
+	}
+	return labels
+}
+
+// Orientation derives the orientation of p from its size. It returns an empty
+// string when the size is not known.
+func Orientation(p Painting) string {
+	if p.Size == nil || p.Size.Width <= 0 || p.Size.Height <= 0 {
+		return ""
+	}
+
+	switch {
+	case p.Size.Width > p.Size.Height:
+		return OrientationLandscape
+	case p.Size.Width < p.Size.Height:
+		return OrientationPortrait
+	}
+	return OrientationSquare
+}
+
+// FacetCounter accumulates counts per facet and value.
+type FacetCounter map[string]map[string]int64
+
+func (c FacetCounter) Add(facet string, value string, n int64) {
+	if value == "" {
+		return
+	}
+	if c[facet] == nil {
+		c[facet] = make(map[string]int64)
+	}
+	c[facet][value] += n
+}
+
+// Count adds p to every facet it belongs to.
+func (c FacetCounter) Count(p Painting) {
+	seen := make(map[string]bool)
+	for _, m := range p.Materials {
+		if !seen[m.ID] {
+			seen[m.ID] = true
+			c.Add(FacetMaterials, m.ID, 1)
+		}
+	}
+	c.Add(FacetAvailability, p.Availability, 1)
+	c.Add(FacetPrices, PriceBucket(p.Price), 1)
+	if p.Date != 0 {
+		c.Add(FacetYears, strconv.Itoa(p.Date.Time().UTC().Year()), 1)
+	}
+	c.Add(FacetOrientations, Orientation(p), 1)
+}
+
+// Facets returns the accumulated counts. Prices follow bucket order, years
+// are ascending and the other facets list the most common values first.
+func (c FacetCounter) Facets() Facets {
+	prices := []FacetCount{}
+	for _, label := range PriceBucketLabels() {
+		if n := c[FacetPrices][label]; n > 0 {
+			prices = append(prices, FacetCount{Value: label, Count: n})
+		}
+	}
+
+	years := c.list(FacetYears)
+	sort.Slice(years, func(i, j int) bool { return years[i].Value < years[j].Value })
+
+	return Facets{
+		Materials:    c.list(FacetMaterials),
+		Availability: c.list(FacetAvailability),
+		Prices:       prices,
+		Years:        years,
+		Orientations: c.list(FacetOrientations),
+	}
+}
+
+func (c FacetCounter) list(facet string) []FacetCount {
+	counts := []FacetCount{}
+	for value, n := range c[facet] {
+		counts = append(counts, FacetCount{Value: value, Count: n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Value < counts[j].Value
+	})
+	return counts
+}
@@ -1,17 +1,32 @@
 package models
 
 import (
+	"context"
+	"time"
+
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type GalleryState interface {
-	List() []Painting
-	Save(Painting) primitive.ObjectID
+	List(context.Context, PaintingQuery) ([]Painting, int64, error)
+	Save(context.Context, Painting) (primitive.ObjectID, error)
+
+	One(context.Context, primitive.ObjectID) (Painting, error)
+	Delete(context.Context, primitive.ObjectID) error
+	Update(context.Context, primitive.ObjectID, bson.M) error
+	// UpdateIf applies update only while the painting matches cond and returns
+	// ErrConflict when it no longer does.
+	UpdateIf(ctx context.Context, id primitive.ObjectID, cond bson.M, update bson.M) error
+
+	// UpdateMaterial refreshes the labels of m in every painting made of it.
+	UpdateMaterial(ctx context.Context, m Material) error
+	// Materials lists the materials paintings are made of, once per ID in ID
+	// order, with the labels of one of the paintings.
+	Materials(context.Context) ([]Material, error)
 
-	One(primitive.ObjectID) Painting
-	Delete(primitive.ObjectID) bool
-	Update(primitive.ObjectID, bson.M) bool
+	Search(ctx context.Context, terms []string, limit int) ([]SearchResult, error)
+	Facets(context.Context, PaintingFilter) (Facets, error)
 }
 
 type Gallery struct {
@@ -22,23 +37,131 @@ func NewGallery(state GalleryState) *Gallery {
 	return &Gallery{state: state}
 }
 
-func (w *Gallery) AddProduct(p Painting) primitive.ObjectID {
-	return w.state.Save(p)
+func (w *Gallery) AddProduct(ctx context.Context, p Painting) (primitive.ObjectID, error) {
+	p.Search = NewSearchKeys(p)
+	if p.Availability != "" && len(p.AvailabilityHistory) == 0 {
+		p.AvailabilityHistory = []AvailabilityChange{{
+			To: p.Availability,
+			At: primitive.NewDateTimeFromTime(time.Now()),
+		}}
+	}
+	return w.state.Save(ctx, p)
+}
+
+func (w *Gallery) ListProducts(ctx context.Context, q PaintingQuery) ([]Painting, int64, error) {
+	return w.state.List(ctx, q)
 }
 
-func (w *Gallery) ListProducts() []Painting {
-	return w.state.List()
+func (w *Gallery) GetOnePainting(ctx context.Context, id primitive.ObjectID) (Painting, error) {
+	return w.state.One(ctx, id)
 }
 
-func (w *Gallery) GetOnePainting(id primitive.ObjectID) Painting {
-	return w.state.One(id)
+func (w *Gallery) DeletePainting(ctx context.Context, id primitive.ObjectID) error {
+	return w.state.Delete(ctx, id)
+}
+
+func (w *Gallery) UpdatePainting(ctx context.Context, id primitive.ObjectID, update bson.M) error {
+	err := w.state.Update(ctx, id, update)
+	if err != nil || !updatesSearchFields(update) {
+		return err
+	}
+	return w.refreshSearch(ctx, id)
+}
+
+// UpdatePaintingIf is UpdatePainting applied only while the painting matches
+// cond. It returns ErrConflict when the painting no longer does.
+func (w *Gallery) UpdatePaintingIf(ctx context.Context, id primitive.ObjectID, cond bson.M, update bson.M) error {
+	err := w.state.UpdateIf(ctx, id, cond, update)
+	if err != nil || !updatesSearchFields(update) {
+		return err
+	}
+	return w.refreshSearch(ctx, id)
+}
 
+func (w *Gallery) refreshSearch(ctx context.Context, id primitive.ObjectID) error {
+	p, err := w.state.One(ctx, id)
+	if err != nil {
+		return err
+	}
+	return w.state.Update(ctx, id, bson.M{"$set": bson.M{"search": NewSearchKeys(p)}})
 }
 
-func (w *Gallery) DeletePainting(id primitive.ObjectID) bool {
-	return w.state.Delete(id)
+// SetAvailability moves a painting to change.To and appends the change to its
+// history. Illegal transitions are rejected unless change.Override is set;
+// deciding who may override is up to the caller.
+func (w *Gallery) SetAvailability(ctx context.Context, id primitive.ObjectID, change AvailabilityChange) (AvailabilityChange, error) {
+	p, err := w.state.One(ctx, id)
+	if err != nil {
+		return AvailabilityChange{}, err
+	}
+
+	change.From = p.Availability
+	err = validateTransition(change.From, change.To, change.Override)
+	if err != nil {
+		return AvailabilityChange{}, err
+	}
+	change.At = primitive.NewDateTimeFromTime(time.Now())
+
+	// Only apply the change if nobody else moved the painting since it was read.
+	cond := bson.M{"availability": p.Availability}
+	if p.Availability == "" {
+		cond = bson.M{"availability": bson.M{"$in": bson.A{nil, ""}}}
+	}
+	update := bson.M{
+		"$set":  bson.M{"availability": change.To},
+		"$push": bson.M{"availabilityHistory": change},
+	}
+	err = w.state.UpdateIf(ctx, id, cond, update)
+	if err != nil {
+		return AvailabilityChange{}, err
+	}
+	return change, nil
+}
+
+func (w *Gallery) AvailabilityHistory(ctx context.Context, id primitive.ObjectID) ([]AvailabilityChange, error) {
+	p, err := w.state.One(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if p.AvailabilityHistory == nil {
+		return []AvailabilityChange{}, nil
+	}
+	return p.AvailabilityHistory, nil
+}
+
+func (w *Gallery) Facets(ctx context.Context, f PaintingFilter) (Facets, error) {
+	return w.state.Facets(ctx, f)
+}
+
+// Search finds paintings matching query in either language and highlights
+// the matching words.
+func (w *Gallery) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	terms := SearchTerms(query)
+	if len(terms) == 0 {
+		return []SearchResult{}, nil
+	}
+
+	results, err := w.state.Search(ctx, terms, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Highlights = Highlight(results[i].Painting, terms)
+	}
+	return results, nil
 }
 
-func (w *Gallery) UpdatePainting(id primitive.ObjectID, update bson.M) bool {
-	return w.state.Update(id, update)
+func updatesSearchFields(update bson.M) bool {
+	for _, arg := range update {
+		fields, ok := arg.(bson.M)
+		if !ok {
+			return true
+		}
+		for _, name := range []string{"title", "titleUkr", "description", "descriptionUkr"} {
+			if _, ok := fields[name]; ok {
+				return true
+			}
+		}
+	}
+	return false
 }
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Statuses of a job.
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// Stages of a running job.
+const (
+	StageProcessing = "processing"
+	StageUploading  = "uploading"
+)
+
+// Job processes the images uploaded for a painting in the background, and
+// fills in the painting's photos once they are stored.
+type Job struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"_id"`
+	PaintingID primitive.ObjectID `bson:"paintingId" json:"paintingId"`
+	Status     string             `bson:"status" json:"status"`
+	Progress   JobProgress        `bson:"progress" json:"progress"`
+	Error      string             `bson:"error,omitempty" json:"error,omitempty"`
+	Failures   []JobFailure       `bson:"failures,omitempty" json:"failures,omitempty"`
+	Attempts   int                `bson:"attempts" json:"attempts"`
+	Created    primitive.DateTime `bson:"created" json:"created"`
+	Updated    primitive.DateTime `bson:"updated" json:"updated"`
+
+	// Workspace holds the uploaded files until the job finishes. Folder is
+	// where they are stored, the same on every attempt so that a retried
+	// upload resumes.
+	Workspace string `bson:"workspace" json:"-"`
+	Folder    string `bson:"folder" json:"-"`
+	Watermark bool   `bson:"watermark" json:"-"`
+	// PhotosSeq is the Painting.PhotosSeq the images were submitted as.
+	PhotosSeq int64 `bson:"photosSeq" json:"-"`
+}
+
+// JobProgress counts the images done in the current stage of a job.
+type JobProgress struct {
+	Stage string `bson:"stage,omitempty" json:"stage,omitempty"`
+	Done  int    `bson:"done" json:"done"`
+	Total int    `bson:"total" json:"total"`
+}
+
+// JobFailure is an image a job could not store.
+type JobFailure struct {
+	Name  string `bson:"name" json:"name"`
+	Error string `bson:"error" json:"error"`
+}
+
+// Finished reports whether the job will not run again.
+func (j Job) Finished() bool {
+	return j.Status == JobDone || j.Status == JobFailed
+}
+
+type JobState interface {
+	Save(context.Context, Job) (primitive.ObjectID, error)
+	One(context.Context, primitive.ObjectID) (Job, error)
+	Update(context.Context, primitive.ObjectID, bson.M) error
+	// Unfinished lists the queued and running jobs, oldest first.
+	Unfinished(context.Context) ([]Job, error)
+}
+
+type Jobs struct {
+	state JobState
+}
+
+func NewJobs(state JobState) *Jobs {
+	return &Jobs{state: state}
+}
+
+// Add queues a new job.
+func (j *Jobs) Add(ctx context.Context, job Job) (Job, error) {
+	now := primitive.NewDateTimeFromTime(time.Now())
+	job.ID = primitive.NewObjectID()
+	job.Status = JobQueued
+	job.Created = now
+	job.Updated = now
+
+	_, err := j.state.Save(ctx, job)
+	if err != nil {
+		return Job{}, err
+	}
+	return job, nil
+}
+
+func (j *Jobs) One(ctx context.Context, id primitive.ObjectID) (Job, error) {
+	return j.state.One(ctx, id)
+}
+
+func (j *Jobs) Unfinished(ctx context.Context) ([]Job, error) {
+	return j.state.Unfinished(ctx)
+}
+
+// Start marks the job running and counts the attempt.
+func (j *Jobs) Start(ctx context.Context, id primitive.ObjectID) error {
+	return j.set(ctx, id, bson.M{"status": JobRunning}, bson.M{"$inc": bson.M{"attempts": 1}})
+}
+
+// Requeue puts back a job that was interrupted rather than failed, without
+// counting the attempt.
+func (j *Jobs) Requeue(ctx context.Context, id primitive.ObjectID) error {
+	return j.set(ctx, id, bson.M{"status": JobQueued, "progress": JobProgress{}}, bson.M{"$inc": bson.M{"attempts": -1}})
+}
+
+func (j *Jobs) Progress(ctx context.Context, id primitive.ObjectID, progress JobProgress) error {
+	return j.set(ctx, id, bson.M{"progress": progress}, nil)
+}
+
+func (j *Jobs) Finish(ctx context.Context, id primitive.ObjectID) error {
+	return j.set(ctx, id, bson.M{"status": JobDone}, nil)
+}
+
+// Fail marks the job failed with err, and the images that could not be
+// stored, if known.
+func (j *Jobs) Fail(ctx context.Context, id primitive.ObjectID, err error, failures []JobFailure) error {
+	return j.set(ctx, id, bson.M{"status": JobFailed, "error": err.Error(), "failures": failures}, nil)
+}
+
+// set applies fields, and the other operators of update, stamping the job
+// as updated.
+func (j *Jobs) set(ctx context.Context, id primitive.ObjectID, fields bson.M, update bson.M) error {
+	if update == nil {
+		update = bson.M{}
+	}
+	fields["updated"] = primitive.NewDateTimeFromTime(time.Now())
+	update["$set"] = fields
+	return j.state.Update(ctx, id, update)
+}
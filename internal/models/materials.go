@@ -0,0 +1,134 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrUnknownMaterial is returned when a painting references a material that
+// is not in the catalogue.
+var ErrUnknownMaterial = errors.New("unknown material")
+
+var materialIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+type MaterialState interface {
+	List(context.Context) ([]Material, error)
+	Save(context.Context, Material) error
+
+	One(ctx context.Context, id string) (Material, error)
+	Delete(ctx context.Context, id string) error
+	Update(context.Context, Material) error
+}
+
+// Materials manages the catalogue of materials paintings are made of. Paintings
+// keep a copy of each material, which is refreshed when a label changes.
+type Materials struct {
+	state   MaterialState
+	gallery GalleryState
+}
+
+func NewMaterials(state MaterialState, gallery GalleryState) *Materials {
+	return &Materials{state: state, gallery: gallery}
+}
+
+func (m Material) Validate() error {
+	if !materialIDPattern.MatchString(m.ID) {
+		return fmt.Errorf("material ID %q must consist of lowercase letters, digits, '-' and '_'", m.ID)
+	}
+	if strings.TrimSpace(m.EN) == "" || strings.TrimSpace(m.UKR) == "" {
+		return errors.New("material EN and UKR labels must be present")
+	}
+	return nil
+}
+
+func (w *Materials) List(ctx context.Context) ([]Material, error) {
+	return w.state.List(ctx)
+}
+
+func (w *Materials) One(ctx context.Context, id string) (Material, error) {
+	return w.state.One(ctx, id)
+}
+
+func (w *Materials) Add(ctx context.Context, m Material) error {
+	return w.state.Save(ctx, m)
+}
+
+// Update changes the labels of a material and of every painting made of it.
+func (w *Materials) Update(ctx context.Context, m Material) error {
+	err := w.state.Update(ctx, m)
+	if err != nil {
+		return err
+	}
+	return w.gallery.UpdateMaterial(ctx, m)
+}
+
+// Delete removes a material that no painting references anymore.
+func (w *Materials) Delete(ctx context.Context, id string) error {
+	_, total, err := w.gallery.List(ctx, PaintingQuery{Filter: PaintingFilter{MaterialID: id}, PerPage: 1})
+	if err != nil {
+		return err
+	}
+	if total > 0 {
+		return fmt.Errorf("material %s is used by %d paintings: %w", id, total, ErrConflict)
+	}
+	return w.state.Delete(ctx, id)
+}
+
+// Import adds the materials paintings are made of to the catalogue, for
+// paintings stored before it existed, which could not be validated against
+// it otherwise. Materials already in the catalogue are left as they are.
+// Those that are not valid catalogue entries are returned as skipped.
+func (w *Materials) Import(ctx context.Context) (imported []Material, skipped []Material, err error) {
+	used, err := w.gallery.Materials(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, m := range used {
+		if m.Validate() != nil {
+			skipped = append(skipped, m)
+			continue
+		}
+		_, err := w.state.One(ctx, m.ID)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return imported, skipped, err
+		}
+		err = w.state.Save(ctx, m)
+		if errors.Is(err, ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return imported, skipped, err
+		}
+		imported = append(imported, m)
+	}
+	return imported, skipped, nil
+}
+
+// Resolve looks up catalogue entries for ids, rejecting unknown IDs.
+func (w *Materials) Resolve(ctx context.Context, ids []string) ([]Material, error) {
+	materials := make([]Material, 0, len(ids))
+	var unknown []string
+	for _, id := range ids {
+		m, err := w.state.One(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			unknown = append(unknown, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		materials = append(materials, m)
+	}
+
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMaterial, strings.Join(unknown, ", "))
+	}
+	return materials, nil
+}
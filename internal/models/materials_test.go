@@ -0,0 +1,67 @@
+package models_test
+
+import (
+	"art/internal/db"
+	"art/internal/models"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestMaterialsImport(t *testing.T) {
+	ctx := context.Background()
+	gallery := db.NewMemoryGalleryState()
+	catalogue := db.NewMemoryMaterialState()
+	materials := models.NewMaterials(catalogue, gallery)
+
+	oil := models.Material{ID: "oil", EN: "Oil", UKR: "Олія"}
+	canvas := models.Material{ID: "canvas", EN: "Canvas", UKR: "Полотно"}
+	for _, p := range []models.Painting{
+		{Title: "Dawn", Materials: []models.Material{oil, {ID: "canvas", EN: "Linen canvas", UKR: "Полотно"}}},
+		{Title: "Dusk", Materials: []models.Material{oil, {EN: "Paper", UKR: "Папір"}}},
+		{Title: "Noon", Materials: []models.Material{{ID: "Gold Leaf", EN: "Gold leaf", UKR: "Сусальне золото"}, {ID: "ink", EN: "Ink"}}},
+		{Title: "Night"},
+	} {
+		_, err := gallery.Save(ctx, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := materials.Add(ctx, canvas)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported, skipped, err := materials.Import(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []models.Material{oil}; !reflect.DeepEqual(imported, want) {
+		t.Errorf("imported %+v, want %+v", imported, want)
+	}
+	var skippedIDs []string
+	for _, m := range skipped {
+		skippedIDs = append(skippedIDs, m.ID)
+	}
+	if want := []string{"", "Gold Leaf", "ink"}; !reflect.DeepEqual(skippedIDs, want) {
+		t.Errorf("skipped %q, want %q", skippedIDs, want)
+	}
+
+	// Materials in the catalogue keep their labels.
+	listed, err := materials.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []models.Material{canvas, oil}; !reflect.DeepEqual(listed, want) {
+		t.Errorf("catalogue %+v, want %+v", listed, want)
+	}
+	_, err = materials.Resolve(ctx, []string{"oil", "canvas"})
+	if err != nil {
+		t.Errorf("imported materials do not resolve: %v", err)
+	}
+
+	imported, _, err = materials.Import(ctx)
+	if err != nil || len(imported) != 0 {
+		t.Errorf("importing again added %+v, %v", imported, err)
+	}
+}
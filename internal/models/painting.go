@@ -8,22 +8,59 @@ type Material struct {
 	UKR string `bson:"ukr,omitempty"`
 }
 
+// Photos are the images of a painting, artist or collection, stored in one
+// folder. Urls holds the largest resized rendition of each image, as served
+// before renditions existed, and Images every rendition. When published
+// renditions are watermarked, the originals are kept in OriginalsFolder of
+// a private store instead, which responses do not reveal.
 type Photos struct {
-	Urls     []string `bson:"images,omitempty"`
-	FolderId string   `bson:"folderId,omitempty"`
+	Urls            []string `bson:"images,omitempty"`
+	FolderId        string   `bson:"folderId,omitempty"`
+	OriginalsFolder string   `bson:"originalsFolder,omitempty" json:"-"`
+	Images          []Image  `bson:"renditions,omitempty"`
+}
+
+// Image is one photo in every rendition it was stored in, keyed by rendition
+// name, e.g. "thumb" or "original".
+type Image struct {
+	Renditions map[string]Rendition `bson:"renditions" json:"renditions"`
+	Metadata   *ImageMetadata       `bson:"metadata,omitempty" json:"metadata,omitempty"`
+}
+
+// ImageMetadata are the EXIF fields chosen to be kept from an upload.
+type ImageMetadata struct {
+	CapturedAt *primitive.DateTime `bson:"capturedAt,omitempty" json:"capturedAt,omitempty"`
+	Camera     string              `bson:"camera,omitempty" json:"camera,omitempty"`
+}
+
+// Rendition is one size of an Image. Private renditions are not published
+// and have no URL.
+type Rendition struct {
+	URL     string `bson:"url" json:"url,omitempty"`
+	Width   int    `bson:"width" json:"width"`
+	Height  int    `bson:"height" json:"height"`
+	Private bool   `bson:"private,omitempty" json:"private,omitempty"`
 }
 
 type Painting struct {
-	ID             primitive.ObjectID `bson:"_id,omitempty" json:"_id"`
-	Images         []string           `bson:"images,omitempty" json:"images"`
-	Photos         Photos             `bson:"photos,omitempty" json:"photos"`
-	Title          string             `bson:"title,omitempty" json:"title"`
-	TitleUkr       string             `bson:"titleUkr,omitempty" json:"titleUkr"`
-	Description    string             `bson:"description,omitempty" json:"description"`
-	DescriptionUkr string             `bson:"descriptionUkr,omitempty" json:"descriptionUkr"`
-	Price          float64            `bson:"price,omitempty" json:"price"`
-	Size           []interface{}      `bson:"size,omitempty" json:"size"`
-	Date           primitive.DateTime `bson:"date,omitempty" json:"date"`
-	Availability   string             `bson:"availability,omitempty" json:"availability"`
-	Materials      []Material         `bson:"materials,omitempty" json:"materials"`
+	ID                  primitive.ObjectID   `bson:"_id,omitempty" json:"_id"`
+	Images              []string             `bson:"images,omitempty" json:"images"`
+	Photos              Photos               `bson:"photos,omitempty" json:"photos"`
+	Title               string               `bson:"title,omitempty" json:"title"`
+	TitleUkr            string               `bson:"titleUkr,omitempty" json:"titleUkr"`
+	Description         string               `bson:"description,omitempty" json:"description"`
+	DescriptionUkr      string               `bson:"descriptionUkr,omitempty" json:"descriptionUkr"`
+	Price               float64              `bson:"price,omitempty" json:"price"`
+	Size                *Dimensions          `bson:"size,omitempty" json:"size"`
+	Date                primitive.DateTime   `bson:"date,omitempty" json:"date"`
+	Availability        string               `bson:"availability,omitempty" json:"availability"`
+	AvailabilityHistory []AvailabilityChange `bson:"availabilityHistory,omitempty" json:"-"`
+	Materials           []Material           `bson:"materials,omitempty" json:"materials"`
+	ArtistID            *primitive.ObjectID  `bson:"artistId,omitempty" json:"artistId,omitempty"`
+	Watermark           *bool                `bson:"watermark,omitempty" json:"watermark,omitempty"`
+	Search              *SearchKeys          `bson:"search,omitempty" json:"-"`
+	// PhotosSeq numbers the images submitted for the painting, so that the
+	// job storing them only replaces its photos if none were submitted
+	// after them.
+	PhotosSeq int64 `bson:"photosSeq,omitempty" json:"-"`
 }
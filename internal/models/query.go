@@ -0,0 +1,180 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PaintingFilter narrows down a painting listing. Zero values mean the
+// corresponding criterion is not applied.
+type PaintingFilter struct {
+	Availability string
+	MaterialID   string
+	ArtistID     primitive.ObjectID
+	// CollectionID is resolved into IDs by Collections.Scope, states only
+	// look at IDs. A nil IDs does not restrict the paintings, an empty one
+	// matches none.
+	CollectionID primitive.ObjectID
+	IDs          []primitive.ObjectID
+	MinPrice     *float64
+	MaxPrice     *float64
+	From         *time.Time
+	To           *time.Time
+	// Size bounds are in centimeters.
+	MinWidth  *float64
+	MaxWidth  *float64
+	MinHeight *float64
+	MaxHeight *float64
+}
+
+// PaintingQuery describes one page of a filtered and sorted painting listing.
+// Sort is a field name ("price", "date" or "title"), prefixed with "-" for
+// descending order. Page is 1-based.
+type PaintingQuery struct {
+	Filter  PaintingFilter
+	Sort    string
+	Page    int
+	PerPage int
+}
+
+// SortFields maps the sort keys accepted in PaintingQuery.Sort to stored fields.
+var SortFields = map[string]string{
+	"price": "price",
+	"date":  "date",
+	"title": "title",
+}
+
+// SortField splits Sort into the stored field name and the direction.
+func (q PaintingQuery) SortField() (field string, descending bool) {
+	key := strings.TrimPrefix(q.Sort, "-")
+	return SortFields[key], strings.HasPrefix(q.Sort, "-")
+}
+
+// Skip returns the number of paintings before the requested page.
+func (q PaintingQuery) Skip() int {
+	if q.Page < 1 {
+		return 0
+	}
+	return (q.Page - 1) * q.PerPage
+}
+
+// Match reports whether p satisfies the filter. It mirrors the Mongo filter
+// built by the db package so state implementations agree on results.
+func (f PaintingFilter) Match(p Painting) bool {
+	if f.Availability != "" && p.Availability != f.Availability {
+		return false
+	}
+	if !f.ArtistID.IsZero() && (p.ArtistID == nil || *p.ArtistID != f.ArtistID) {
+		return false
+	}
+	if f.IDs != nil {
+		found := false
+		for _, id := range f.IDs {
+			if id == p.ID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.MaterialID != "" {
+		found := false
+		for _, m := range p.Materials {
+			if m.ID == f.MaterialID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.MinPrice != nil && p.Price < *f.MinPrice {
+		return false
+	}
+	if f.MaxPrice != nil && p.Price > *f.MaxPrice {
+		return false
+	}
+	if f.From != nil && p.Date.Time().Before(*f.From) {
+		return false
+	}
+	if f.To != nil && p.Date.Time().After(*f.To) {
+		return false
+	}
+	if f.MinWidth != nil || f.MaxWidth != nil || f.MinHeight != nil || f.MaxHeight != nil {
+		if p.Size == nil {
+			return false
+		}
+		if f.MinWidth != nil && p.Size.Width < *f.MinWidth {
+			return false
+		}
+		if f.MaxWidth != nil && p.Size.Width > *f.MaxWidth {
+			return false
+		}
+		if f.MinHeight != nil && p.Size.Height < *f.MinHeight {
+			return false
+		}
+		if f.MaxHeight != nil && p.Size.Height > *f.MaxHeight {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply filters, sorts and paginates paintings in memory and returns the page
+// together with the number of paintings matching the filter.
+func (q PaintingQuery) Apply(paintings []Painting) ([]Painting, int64) {
+	matched := make([]Painting, 0, len(paintings))
+	for _, p := range paintings {
+		if q.Filter.Match(p) {
+			matched = append(matched, p)
+		}
+	}
+
+	field, descending := q.SortField()
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		var cmp int
+		switch field {
+		case "price":
+			cmp = compare(a.Price < b.Price, a.Price > b.Price)
+		case "date":
+			cmp = compare(a.Date < b.Date, a.Date > b.Date)
+		case "title":
+			cmp = strings.Compare(a.Title, b.Title)
+		}
+		if descending {
+			cmp = -cmp
+		}
+		if cmp == 0 {
+			return a.ID.Hex() < b.ID.Hex()
+		}
+		return cmp < 0
+	})
+
+	total := int64(len(matched))
+	skip := q.Skip()
+	if skip >= len(matched) {
+		return []Painting{}, total
+	}
+	matched = matched[skip:]
+	if q.PerPage > 0 && len(matched) > q.PerPage {
+		matched = matched[:q.PerPage]
+	}
+	return matched, total
+}
+
+func compare(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,245 @@
+package models
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SearchKeys holds the folded text a painting is searched by. It is derived
+// from the bilingual title and description and is never sent to clients.
+type SearchKeys struct {
+	Title       string `bson:"title"`
+	Description string `bson:"description"`
+}
+
+// SearchResult is a painting matched by a search with its relevance score and
+// highlighted snippets keyed by the JSON name of the matching field.
+type SearchResult struct {
+	Painting   Painting          `json:"painting"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+const (
+	titleWeight       = 10
+	descriptionWeight = 2
+	snippetRadius     = 60
+)
+
+var cyrillic = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "h", 'ґ': "g", 'д': "d", 'е': "e",
+	'є': "ye", 'ж': "zh", 'з': "z", 'и': "y", 'і': "i", 'ї': "i", 'й': "y",
+	'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r",
+	'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch",
+	'ш': "sh", 'щ': "shch", 'ь': "", 'ю': "yu", 'я': "ya",
+	'ё': "yo", 'ъ': "", 'ы': "y", 'э': "e",
+	'\'': "", '’': "", 'ʼ': "",
+}
+
+// Latin spellings that different romanizations of the same Ukrainian word
+// disagree on, folded to a single form.
+var latinFolds = strings.NewReplacer("ia", "ya", "iu", "yu", "ie", "ye", "ii", "iy", "j", "y")
+
+// Fold lowercases a word and transliterates Cyrillic into Latin, so that
+// "Соняшники", "sonyashnyky" and "soniashnyky" all fold to the same key.
+func Fold(word string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(word) {
+		if latin, ok := cyrillic[r]; ok {
+			b.WriteString(latin)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return latinFolds.Replace(b.String())
+}
+
+type token struct {
+	start, end int
+	key        string
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' || r == '’' || r == 'ʼ'
+}
+
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, token{start: start, end: i, key: Fold(text[start:i])})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{start: start, end: len(text), key: Fold(text[start:])})
+	}
+	return tokens
+}
+
+func foldText(texts ...string) string {
+	var keys []string
+	for _, text := range texts {
+		for _, t := range tokenize(text) {
+			if t.key != "" {
+				keys = append(keys, t.key)
+			}
+		}
+	}
+	return strings.Join(keys, " ")
+}
+
+// NewSearchKeys folds the searchable fields of p.
+func NewSearchKeys(p Painting) *SearchKeys {
+	return &SearchKeys{
+		Title:       foldText(p.Title, p.TitleUkr),
+		Description: foldText(p.Description, p.DescriptionUkr),
+	}
+}
+
+// SearchTerms folds a user query into unique search keys.
+func SearchTerms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, t := range tokenize(query) {
+		if t.key != "" && !seen[t.key] {
+			seen[t.key] = true
+			terms = append(terms, t.key)
+		}
+	}
+	return terms
+}
+
+// Score ranks p against folded terms the way the Mongo text index is weighted:
+// title matches count more than description matches.
+func (k SearchKeys) Score(terms []string) float64 {
+	wanted := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		wanted[term] = true
+	}
+
+	var score float64
+	for _, key := range strings.Fields(k.Title) {
+		if wanted[key] {
+			score += titleWeight
+		}
+	}
+	for _, key := range strings.Fields(k.Description) {
+		if wanted[key] {
+			score += descriptionWeight
+		}
+	}
+	return score
+}
+
+// SearchPaintings scores paintings in memory and returns the best limit
+// matches, highest score first.
+func SearchPaintings(paintings []Painting, terms []string, limit int) []SearchResult {
+	var results []SearchResult
+	for _, p := range paintings {
+		keys := p.Search
+		if keys == nil {
+			keys = NewSearchKeys(p)
+		}
+		if score := keys.Score(terms); score > 0 {
+			results = append(results, SearchResult{Painting: p, Score: score})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// Highlight returns HTML-escaped snippets of the fields of p that contain any
+// of terms, with matching words wrapped in <mark>.
+func Highlight(p Painting, terms []string) map[string]string {
+	fields := map[string]string{
+		"title":          p.Title,
+		"titleUkr":       p.TitleUkr,
+		"description":    p.Description,
+		"descriptionUkr": p.DescriptionUkr,
+	}
+
+	highlights := make(map[string]string)
+	for name, text := range fields {
+		if snippet, ok := snippet(text, terms); ok {
+			highlights[name] = snippet
+		}
+	}
+	return highlights
+}
+
+func snippet(text string, terms []string) (string, bool) {
+	wanted := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		wanted[term] = true
+	}
+
+	var matches []token
+	for _, t := range tokenize(text) {
+		if wanted[t.key] {
+			matches = append(matches, t)
+		}
+	}
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	from := wordBoundary(text, matches[0].start-snippetRadius, false)
+	to := wordBoundary(text, matches[0].end+snippetRadius, true)
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for _, m := range matches {
+		if m.start < from || m.end > to {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:m.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[m.start:m.end]))
+		b.WriteString("</mark>")
+		pos = m.end
+	}
+	b.WriteString(html.EscapeString(text[pos:to]))
+	if to < len(text) {
+		b.WriteString("…")
+	}
+	return b.String(), true
+}
+
+// wordBoundary moves i to the nearest whitespace so snippets do not cut words.
+func wordBoundary(text string, i int, forward bool) int {
+	if i <= 0 {
+		return 0
+	}
+	if i >= len(text) {
+		return len(text)
+	}
+	if forward {
+		if j := strings.IndexAny(text[i:], " \t\n"); j >= 0 {
+			return i + j
+		}
+		return len(text)
+	}
+	if j := strings.LastIndexAny(text[:i], " \t\n"); j >= 0 {
+		return j + 1
+	}
+	return 0
+}
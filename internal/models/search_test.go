@@ -0,0 +1,137 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{word: "SUNFLOWERS", want: "sunflowers"},
+		{word: "Соняшники", want: "sonyashnyky"},
+		{word: "sonyashnyky", want: "sonyashnyky"},
+		{word: "soniashnyky", want: "sonyashnyky"},
+		{word: "Київ", want: "kyiv"},
+		{word: "Kyiv", want: "kyiv"},
+		{word: "Ґанок", want: "ganok"},
+		{word: "Мар'яна", want: "maryana"},
+		{word: "Марʼяна", want: "maryana"},
+		{word: "Юрій", want: "yuriy"},
+		{word: "Iurii", want: "yuriy"},
+		{word: "Yevhen", want: "yevhen"},
+		{word: "Ievhen", want: "yevhen"},
+		{word: "Щастя", want: "shchastya"},
+		{word: "Ёлка", want: "yolka"},
+		{word: "1888", want: "1888"},
+		{word: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := Fold(tt.word); got != tt.want {
+			t.Errorf("Fold(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "sunflowers", want: []string{"sunflowers"}},
+		{query: "  Sunflowers, by   Van-Gogh! ", want: []string{"sunflowers", "by", "van", "gogh"}},
+		// Spellings of one word give one term.
+		{query: "Соняшники sonyashnyky SONIASHNYKY", want: []string{"sonyashnyky"}},
+		{query: "sun sunflowers sun", want: []string{"sun", "sunflowers"}},
+		{query: "Мар'яна", want: []string{"maryana"}},
+		{query: "-- ,. !"},
+		{query: ""},
+	}
+
+	for _, tt := range tests {
+		if got := SearchTerms(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SearchTerms(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name     string
+		painting Painting
+		query    string
+		want     map[string]string
+	}{
+		{
+			name:     "whole words",
+			painting: Painting{Title: "Sunflowers in the sun"},
+			query:    "sun sunflowers",
+			want:     map[string]string{"title": "<mark>Sunflowers</mark> in the <mark>sun</mark>"},
+		},
+		{
+			name:     "prefix is not a match",
+			painting: Painting{Title: "Sunflowers"},
+			query:    "sun",
+			want:     map[string]string{},
+		},
+		{
+			name:     "transliterated",
+			painting: Painting{Title: "Sunflowers", TitleUkr: "Соняшники на сонці"},
+			query:    "soniashnyky",
+			want:     map[string]string{"titleUkr": "<mark>Соняшники</mark> на сонці"},
+		},
+		{
+			name:     "after multibyte text",
+			painting: Painting{TitleUkr: "Жовті соняшники"},
+			query:    "соняшники жовті",
+			want:     map[string]string{"titleUkr": "<mark>Жовті</mark> <mark>соняшники</mark>"},
+		},
+		{
+			name:     "escaped",
+			painting: Painting{Description: "Tom & <Jerry>"},
+			query:    "jerry",
+			want:     map[string]string{"description": "Tom &amp; &lt;<mark>Jerry</mark>&gt;"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Highlight(tt.painting, SearchTerms(tt.query))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	filler := strings.Repeat("слово ", 20)
+	p := Painting{DescriptionUkr: filler + "соняшники " + filler}
+
+	got := Highlight(p, SearchTerms("соняшники"))["descriptionUkr"]
+	if !utf8.ValidString(got) {
+		t.Fatalf("snippet %q is not valid UTF-8", got)
+	}
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") {
+		t.Errorf("snippet %q is not cut on both sides", got)
+	}
+	if len(got) >= len(p.DescriptionUkr) {
+		t.Errorf("snippet %q is not shorter than the text", got)
+	}
+
+	// Snippets are cut between words, counted in bytes rather than runes.
+	trimmed := strings.NewReplacer("…", "", "<mark>", "", "</mark>", "").Replace(got)
+	for _, word := range strings.Fields(trimmed) {
+		if word != "слово" && word != "соняшники" {
+			t.Errorf("snippet %q cuts a word: %q", got, word)
+		}
+	}
+	if !strings.Contains(got, "<mark>соняшники</mark>") {
+		t.Errorf("snippet %q does not mark the match", got)
+	}
+}
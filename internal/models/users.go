@@ -2,6 +2,7 @@ package models
 
 import (
 	"art/internal/types"
+	"context"
 	"crypto/sha1"
 	"encoding/hex"
 )
@@ -9,50 +10,58 @@ import (
 type User struct {
 	Login    types.Login
 	Password types.Password
+	// Admin users may override restricted operations such as illegal
+	// availability transitions. It can only be granted in the database.
+	Admin bool `bson:"admin,omitempty"`
 }
 
 type UserState interface {
-	Register(user User) error
-	Login(user User) (User, error)
-	SetAuthenticated(session string, authenticated bool)
-	IsAuthenticated(session string) bool
+	Register(ctx context.Context, user User) error
+	Login(ctx context.Context, user User) (User, error)
+	User(ctx context.Context, login types.Login) (User, error)
+	SetAuthenticated(ctx context.Context, session string, login types.Login)
+	IsAuthenticated(ctx context.Context, session string) (types.Login, bool)
 }
 
 type Users struct {
-	state         UserState
-	Registered    map[types.Login]types.Password
-	Authenticated map[string]bool
+	state UserState
 }
 
 func NewUsers(state UserState) *Users {
 	return &Users{
-		state:         state,
-		Registered:    make(map[types.Login]types.Password),
-		Authenticated: make(map[string]bool),
+		state: state,
 	}
 }
 
-func (w *Users) Register(user User) error {
+func (w *Users) Register(ctx context.Context, user User) error {
 	h := sha1.New()
 	h.Write([]byte(user.Password))
 	user.Password = types.Password(hex.EncodeToString(h.Sum(nil)))
 
-	return w.state.Register(user)
+	return w.state.Register(ctx, user)
 }
 
-func (w *Users) Login(user User) (User, error) {
+func (w *Users) Login(ctx context.Context, user User) (User, error) {
 	h := sha1.New()
 	h.Write([]byte(user.Password))
 	user.Password = types.Password(hex.EncodeToString(h.Sum(nil)))
 
-	return w.state.Login(user)
+	return w.state.Login(ctx, user)
 }
 
-func (w *Users) SetAuthenticated(session string, authenticated bool) {
-	w.Authenticated[session] = authenticated
+func (w *Users) SetAuthenticated(ctx context.Context, session string, login types.Login) {
+	w.state.SetAuthenticated(ctx, session, login)
 }
 
-func (w *Users) IsAuthenticated(session string) bool {
-	authenticated, exists := w.Authenticated[session]
-	return exists && authenticated
+// IsAuthenticated returns the login the session belongs to.
+func (w *Users) IsAuthenticated(ctx context.Context, session string) (types.Login, bool) {
+	return w.state.IsAuthenticated(ctx, session)
+}
+
+func (w *Users) IsAdmin(ctx context.Context, login types.Login) (bool, error) {
+	user, err := w.state.User(ctx, login)
+	if err != nil {
+		return false, err
+	}
+	return user.Admin, nil
 }
@@ -0,0 +1,201 @@
+package photoprocessor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Metadata are the EXIF fields that may be published with an image. Location
+// and other private fields are never read.
+type Metadata struct {
+	CapturedAt time.Time
+	Camera     string
+}
+
+// Names of the Metadata fields, to choose which are kept.
+const (
+	FieldCaptured = "captured"
+	FieldCamera   = "camera"
+)
+
+// ParseMetadataFields reads a comma separated list of Metadata field names.
+// An empty string keeps no fields.
+func ParseMetadataFields(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var fields []string
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field != FieldCaptured && field != FieldCamera {
+			return nil, fmt.Errorf("Unknown metadata field %q", field)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// Keep returns m with only the named fields set.
+func (m Metadata) Keep(fields []string) Metadata {
+	var kept Metadata
+	for _, field := range fields {
+		switch field {
+		case FieldCaptured:
+			kept.CapturedAt = m.CapturedAt
+		case FieldCamera:
+			kept.Camera = m.Camera
+		}
+	}
+	return kept
+}
+
+const (
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagExifIFD          = 0x8769
+	tagDateTimeOriginal = 0x9003
+)
+
+// ReadMetadata reads the EXIF metadata of a JPEG file. Files without EXIF
+// data, including other formats, have empty metadata.
+func ReadMetadata(path string) (Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Metadata{}, err
+	}
+	defer f.Close()
+
+	tiff, err := exifSegment(bufio.NewReader(f))
+	if err != nil || tiff == nil {
+		return Metadata{}, err
+	}
+	return parseExif(tiff), nil
+}
+
+// exifSegment returns the TIFF structure of the APP1 Exif segment of a JPEG,
+// or nil if there is none.
+func exifSegment(r *bufio.Reader) ([]byte, error) {
+	var soi [2]byte
+	_, err := io.ReadFull(r, soi[:])
+	if err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return nil, nil
+	}
+
+	for {
+		var marker [2]byte
+		_, err := io.ReadFull(r, marker[:])
+		if err != nil {
+			return nil, nil
+		}
+		if marker[0] != 0xFF || marker[1] == 0xDA || marker[1] == 0xD9 {
+			// Image data starts, there are no more metadata segments.
+			return nil, nil
+		}
+
+		var length uint16
+		err = binary.Read(r, binary.BigEndian, &length)
+		if err != nil || length < 2 {
+			return nil, nil
+		}
+		segment := make([]byte, length-2)
+		_, err = io.ReadFull(r, segment)
+		if err != nil {
+			return nil, nil
+		}
+		if marker[1] == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:], nil
+		}
+	}
+}
+
+func parseExif(tiff []byte) Metadata {
+	if len(tiff) < 8 {
+		return Metadata{}
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return Metadata{}
+	}
+
+	var m Metadata
+	ifd0 := readIFD(tiff, order, order.Uint32(tiff[4:8]))
+	maker, model := strings.TrimSpace(ifd0.ascii(tagMake)), strings.TrimSpace(ifd0.ascii(tagModel))
+	// Models often repeat the maker, e.g. "Canon" and "Canon EOS 5D".
+	if !strings.HasPrefix(model, maker) {
+		model = strings.TrimSpace(maker + " " + model)
+	}
+	m.Camera = model
+
+	if offset, ok := ifd0.long(tagExifIFD); ok {
+		sub := readIFD(tiff, order, offset)
+		captured, err := time.Parse("2006:01:02 15:04:05", sub.ascii(tagDateTimeOriginal))
+		if err == nil {
+			m.CapturedAt = captured
+		}
+	}
+	return m
+}
+
+// ifd is an EXIF image file directory.
+type ifd struct {
+	tiff    []byte
+	order   binary.ByteOrder
+	entries map[uint16][]byte
+}
+
+// readIFD reads the 12 byte entries of the directory at offset. Malformed
+// directories read as empty.
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) ifd {
+	d := ifd{tiff: tiff, order: order, entries: make(map[uint16][]byte)}
+	if uint64(offset)+2 > uint64(len(tiff)) {
+		return d
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	start := int(offset) + 2
+	for i := 0; i < count; i++ {
+		entry := start + 12*i
+		if entry+12 > len(tiff) {
+			break
+		}
+		d.entries[order.Uint16(tiff[entry:])] = tiff[entry : entry+12]
+	}
+	return d
+}
+
+func (d ifd) long(tag uint16) (uint32, bool) {
+	entry, ok := d.entries[tag]
+	if !ok || d.order.Uint16(entry[2:]) != 4 {
+		return 0, false
+	}
+	return d.order.Uint32(entry[8:]), true
+}
+
+func (d ifd) ascii(tag uint16) string {
+	entry, ok := d.entries[tag]
+	if !ok || d.order.Uint16(entry[2:]) != 2 {
+		return ""
+	}
+	count := d.order.Uint32(entry[4:])
+	value := entry[8:12]
+	if count > 4 {
+		offset := d.order.Uint32(entry[8:])
+		if uint64(offset)+uint64(count) > uint64(len(d.tiff)) {
+			return ""
+		}
+		value = d.tiff[offset : offset+count]
+	} else {
+		value = value[:count]
+	}
+	return string(bytes.TrimRight(value, "\x00"))
+}
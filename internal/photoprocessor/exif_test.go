@@ -0,0 +1,163 @@
+package photoprocessor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// Offsets in the TIFF structure built by buildTIFF.
+const (
+	testIFD0      = 8
+	testSubIFD    = testIFD0 + 2 + 3*12 + 4
+	testData      = testSubIFD + 2 + 12 + 4
+	testMakeValue = testIFD0 + 2 + 8
+	testExifValue = testIFD0 + 2 + 2*12 + 8
+)
+
+// buildTIFF builds the TIFF structure of an EXIF segment: IFD0 holds the
+// maker, model and a pointer to the Exif IFD, which holds the capture time.
+func buildTIFF(order binary.ByteOrder, maker, model, captured string) []byte {
+	tiff := make([]byte, testData)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], testIFD0)
+
+	entry := func(at int, tag, typ uint16, count uint32) []byte {
+		order.PutUint16(tiff[at:], tag)
+		order.PutUint16(tiff[at+2:], typ)
+		order.PutUint32(tiff[at+4:], count)
+		return tiff[at+8 : at+12]
+	}
+	var data []byte
+	ascii := func(at int, tag uint16, s string) {
+		s += "\x00"
+		value := entry(at, tag, 2, uint32(len(s)))
+		if len(s) <= 4 {
+			copy(value, s)
+			return
+		}
+		order.PutUint32(value, uint32(testData+len(data)))
+		data = append(data, s...)
+	}
+
+	order.PutUint16(tiff[testIFD0:], 3)
+	ascii(testIFD0+2, tagMake, maker)
+	ascii(testIFD0+2+12, tagModel, model)
+	order.PutUint32(entry(testIFD0+2+2*12, tagExifIFD, 4, 1), testSubIFD)
+	order.PutUint16(tiff[testSubIFD:], 1)
+	ascii(testSubIFD+2, tagDateTimeOriginal, captured)
+	return append(tiff, data...)
+}
+
+// buildJPEG wraps segments, given as marker and content, in a JPEG with no
+// image data.
+func buildJPEG(segments ...[]byte) []byte {
+	b := []byte{0xFF, 0xD8}
+	for _, s := range segments {
+		b = append(b, 0xFF, s[0])
+		b = binary.BigEndian.AppendUint16(b, uint16(len(s)+1))
+		b = append(b, s[1:]...)
+	}
+	return append(b, 0xFF, 0xDA, 0x00, 0x02)
+}
+
+func exifApp1(tiff []byte) []byte {
+	return append([]byte("\xE1Exif\x00\x00"), tiff...)
+}
+
+func TestParseExif(t *testing.T) {
+	captured := time.Date(2021, time.May, 4, 13, 30, 0, 0, time.UTC)
+	full := buildTIFF(binary.LittleEndian, "Canon", "Canon EOS 5D", "2021:05:04 13:30:00")
+	corrupt := func(tiff []byte, at int, value uint32) []byte {
+		tiff = bytes.Clone(tiff)
+		binary.LittleEndian.PutUint32(tiff[at:], value)
+		return tiff
+	}
+
+	tests := []struct {
+		name string
+		tiff []byte
+		want Metadata
+	}{
+		{name: "little endian", tiff: full, want: Metadata{Camera: "Canon EOS 5D", CapturedAt: captured}},
+		{name: "big endian", tiff: buildTIFF(binary.BigEndian, "NIKON", "D850", "2021:05:04 13:30:00"), want: Metadata{Camera: "NIKON D850", CapturedAt: captured}},
+		{name: "inline values", tiff: buildTIFF(binary.LittleEndian, "GE", "X5", "bad"), want: Metadata{Camera: "GE X5"}},
+		{name: "empty"},
+		{name: "truncated header", tiff: full[:7]},
+		{name: "unknown byte order", tiff: append([]byte("XX"), full[2:]...)},
+		{name: "IFD past the end", tiff: corrupt(full, 4, 1<<31)},
+		{name: "IFD offset overflows", tiff: corrupt(full, 4, 0xFFFFFFFF)},
+		{name: "value past the end", tiff: corrupt(full, testMakeValue, uint32(len(full))), want: Metadata{Camera: "Canon EOS 5D", CapturedAt: captured}},
+		{name: "value offset overflows", tiff: corrupt(full, testMakeValue, 0xFFFFFFFE), want: Metadata{Camera: "Canon EOS 5D", CapturedAt: captured}},
+		{name: "Exif IFD past the end", tiff: corrupt(full, testExifValue, 0x7FFFFFFF), want: Metadata{Camera: "Canon EOS 5D"}},
+		{name: "truncated entries", tiff: full[:testIFD0+2+12+6], want: Metadata{}},
+		{name: "truncated data", tiff: full[:testData+3], want: Metadata{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseExif(tt.tiff); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExifSegment(t *testing.T) {
+	tiff := buildTIFF(binary.BigEndian, "NIKON", "D850", "2021:05:04 13:30:00")
+	app0 := []byte("\xE0JFIF\x00\x01\x01")
+
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{name: "exif", data: buildJPEG(exifApp1(tiff)), want: tiff},
+		{name: "after other segments", data: buildJPEG(app0, []byte("\xE1http://ns.adobe.com/xap/1.0/\x00"), exifApp1(tiff)), want: tiff},
+		{name: "none", data: buildJPEG(app0)},
+		{name: "after the image data", data: append(buildJPEG(app0), buildJPEG(exifApp1(tiff))[2:]...)},
+		{name: "not a jpeg", data: []byte("\x89PNG\r\n\x1a\n")},
+		{name: "empty"},
+		{name: "truncated segment", data: buildJPEG(exifApp1(tiff))[:30]},
+		{name: "truncated length", data: []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00}},
+		{name: "length too short", data: []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x01, 'E', 'x'}},
+		{name: "not a marker", data: []byte{0xFF, 0xD8, 0x00, 0xE1, 0x00, 0x04, 0x00, 0x00}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := exifSegment(bufio.NewReader(bytes.NewReader(tt.data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// FuzzExif feeds uploads to the parser the job workers run, which must not
+// panic whatever it reads.
+func FuzzExif(f *testing.F) {
+	tiff := buildTIFF(binary.LittleEndian, "Canon", "Canon EOS 5D", "2021:05:04 13:30:00")
+	f.Add(buildJPEG(exifApp1(tiff)))
+	f.Add(buildJPEG(exifApp1(buildTIFF(binary.BigEndian, "GE", "X5", "2021:05:04 13:30:00"))))
+	f.Add(buildJPEG(exifApp1(tiff[:20])))
+	f.Add([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		segment, _ := exifSegment(bufio.NewReader(bytes.NewReader(data)))
+		parseExif(segment)
+		// Also parse the input as the TIFF structure, which the fuzzer
+		// mutates more directly.
+		parseExif(data)
+	})
+}
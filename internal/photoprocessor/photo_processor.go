@@ -1,12 +1,14 @@
 package photoprocessor
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/disintegration/imaging"
 	"io"
 	"mime/multipart"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type PhotoProcessor interface {
@@ -17,6 +19,9 @@ type PhotoProcessor interface {
 
 type LocalPhotoProcessor struct{}
 
+// SavePhotos saves files in path, with the extension of their detected
+// format. Their names start with their position, so that listing path keeps
+// the order they were sent in.
 func (p *LocalPhotoProcessor) SavePhotos(files []*multipart.FileHeader, path string) error {
 
 	if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -26,27 +31,44 @@ func (p *LocalPhotoProcessor) SavePhotos(files []*multipart.FileHeader, path str
 		}
 	}
 
-	for _, file := range files {
-		src, err := file.Open()
+	for i, file := range files {
+		err := savePhoto(file, path, fmt.Sprintf("%03d-*", i))
 		if err != nil {
 			return err
 		}
-		defer src.Close()
+	}
 
-		dst, err := os.CreateTemp(path, "*.jpg")
-		if err != nil {
-			return err
-		}
-		defer dst.Close()
+	return nil
+}
 
-		_, err = io.Copy(dst, src)
-		if err != nil {
-			return err
-		}
+func savePhoto(file *multipart.FileHeader, path string, pattern string) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
 	}
+	defer src.Close()
 
-	return nil
+	header := make([]byte, 16)
+	n, _ := io.ReadFull(src, header)
+	format, ok := Sniff(header[:n])
+	if !ok {
+		return fmt.Errorf("%s is not a supported image", file.Filename)
+	}
+
+	dst, err := os.CreateTemp(path, pattern+format.Ext)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, io.MultiReader(bytes.NewReader(header[:n]), src))
+	return err
 }
+
+// ResizePhotos writes the image at inputPath to outputPath with a long side
+// of newLongSide, or at its size if newLongSide is 0. The image is turned
+// upright according to its EXIF orientation and, being re-encoded, loses its
+// metadata.
 func (p *LocalPhotoProcessor) ResizePhotos(inputPath string, outputPath string, newLongSide int) error {
 	outputDir := filepath.Dir(outputPath)
 	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
@@ -56,11 +78,16 @@ func (p *LocalPhotoProcessor) ResizePhotos(inputPath string, outputPath string,
 		}
 	}
 
-	img, err := imaging.Open(inputPath)
+	img, err := imaging.Open(inputPath, imaging.AutoOrientation(true))
 	if err != nil {
 		return err
 	}
 
+	// Smaller images are stored as they are rather than enlarged.
+	if newLongSide == 0 || (img.Bounds().Dx() <= newLongSide && img.Bounds().Dy() <= newLongSide) {
+		return imaging.Save(img, outputPath)
+	}
+
 	var newWidth, newHeight int
 	if img.Bounds().Dx() > img.Bounds().Dy() {
 		newWidth = newLongSide
@@ -92,32 +119,76 @@ func (p *LocalPhotoProcessor) RemoveFolder(tempFolderPath string) error {
 	return nil
 }
 
-func SaveAndResizeFiles(processor PhotoProcessor, files []*multipart.FileHeader, savePath string, resizePath string) error {
-
-	err := processor.SavePhotos(files, savePath)
+// SaveAndResizeFiles saves files in the workspace and processes them with
+// ResizeFiles.
+func SaveAndResizeFiles(processor PhotoProcessor, files []*multipart.FileHeader, ws *Workspace, renditions []Rendition, watermark *Watermark) ([]Photo, error) {
+	err := processor.SavePhotos(files, ws.Originals())
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return ResizeFiles(processor, ws, renditions, watermark, nil)
+}
+
+// ResizeFiles processes each file saved in ws.Originals() into every
+// rendition, watermarking those watermark applies to, if any. The renditions
+// are written to ws.Resized() as <name>-<rendition><ext>, and returned in the
+// order files were sent in. progress, if not nil, is called after each file
+// with how many of them are done.
+func ResizeFiles(processor PhotoProcessor, ws *Workspace, renditions []Rendition, watermark *Watermark, progress func(done int, total int)) ([]Photo, error) {
+	savePath, resizePath := ws.Originals(), ws.Resized()
 
-	savedFiles, err := os.ReadDir(savePath)
+	entries, err := os.ReadDir(savePath)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	for _, savedFile := range savedFiles {
-
-		if !savedFile.Type().IsRegular() {
-			continue
+	var savedFiles []os.DirEntry
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			savedFiles = append(savedFiles, entry)
 		}
+	}
+	err = os.MkdirAll(resizePath, 0755)
+	if err != nil {
+		return nil, err
+	}
 
+	var photos []Photo
+	for i, savedFile := range savedFiles {
 		inputPath := filepath.Join(savePath, savedFile.Name())
-		outputPath := filepath.Join(resizePath, savedFile.Name())
+		ext := filepath.Ext(savedFile.Name())
+		base := strings.TrimSuffix(savedFile.Name(), ext)
 
-		err = processor.ResizePhotos(inputPath, outputPath, 1500)
+		var photo Photo
+		photo.Metadata, err = ReadMetadata(inputPath)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		// Even the original is re-encoded, so that no rendition published
+		// keeps the location and other metadata of the upload.
+		for _, r := range renditions {
+			outputPath := filepath.Join(resizePath, base+"-"+r.Name+ext)
+			err = processor.ResizePhotos(inputPath, outputPath, r.LongSide)
+			if err != nil {
+				return nil, err
+			}
+			if watermark.Applies(r.Name) {
+				err = ApplyWatermark(outputPath, watermark)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			width, height, err := dimensions(outputPath)
+			if err != nil {
+				return nil, err
+			}
+			photo.Files = append(photo.Files, File{Rendition: r.Name, Path: outputPath, Width: width, Height: height})
+		}
+		photos = append(photos, photo)
+		if progress != nil {
+			progress(i+1, len(savedFiles))
 		}
 	}
 
-	return nil
+	return photos, nil
 }
@@ -0,0 +1,80 @@
+package photoprocessor
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Rendition is a size every uploaded image is stored in, given by its long
+// side in pixels. A LongSide of 0 keeps the size of the upload.
+type Rendition struct {
+	Name     string
+	LongSide int
+}
+
+var DefaultRenditions = []Rendition{
+	{Name: "thumb", LongSide: 300},
+	{Name: "medium", LongSide: 800},
+	{Name: "full", LongSide: 1500},
+	{Name: "original"},
+}
+
+// ParseRenditions reads renditions written as a comma separated list of
+// name=longSide, or just name for the original, e.g.
+// "thumb=300,full=1500,original".
+func ParseRenditions(s string) ([]Rendition, error) {
+	var renditions []Rendition
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(s, ",") {
+		name, sizeStr, hasSize := strings.Cut(strings.TrimSpace(item), "=")
+		if name == "" || strings.ContainsAny(name, `/\ `) {
+			return nil, fmt.Errorf("Invalid rendition %q", item)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("Rendition %q is listed more than once", name)
+		}
+		seen[name] = true
+
+		r := Rendition{Name: name}
+		if hasSize {
+			size, err := strconv.Atoi(sizeStr)
+			if err != nil || size <= 0 {
+				return nil, fmt.Errorf("Invalid rendition size %q", item)
+			}
+			r.LongSide = size
+		}
+		renditions = append(renditions, r)
+	}
+	return renditions, nil
+}
+
+// Photo is one uploaded image, processed into every rendition.
+type Photo struct {
+	Files    []File
+	Metadata Metadata
+}
+
+// File is one rendition of a Photo.
+type File struct {
+	Rendition string
+	Path      string
+	Width     int
+	Height    int
+}
+
+func dimensions(path string) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, err
+	}
+	return config.Width, config.Height, nil
+}
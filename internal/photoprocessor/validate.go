@@ -0,0 +1,160 @@
+package photoprocessor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"mime/multipart"
+	"strings"
+)
+
+// Format is an image format recognised by its magic bytes.
+type Format struct {
+	Name string
+	Ext  string
+	MIME string
+}
+
+// formats are the formats that can be decoded and resized.
+var formats = []struct {
+	Format
+	magic [][]byte
+}{
+	{Format{"jpeg", ".jpg", "image/jpeg"}, [][]byte{{0xFF, 0xD8, 0xFF}}},
+	{Format{"png", ".png", "image/png"}, [][]byte{[]byte("\x89PNG\r\n\x1a\n")}},
+	{Format{"gif", ".gif", "image/gif"}, [][]byte{[]byte("GIF87a"), []byte("GIF89a")}},
+	{Format{"tiff", ".tif", "image/tiff"}, [][]byte{[]byte("II*\x00"), []byte("MM\x00*")}},
+	{Format{"bmp", ".bmp", "image/bmp"}, [][]byte{[]byte("BM")}},
+}
+
+// Sniff detects the format of an image from its first bytes.
+func Sniff(header []byte) (Format, bool) {
+	for _, f := range formats {
+		for _, magic := range f.magic {
+			if bytes.HasPrefix(header, magic) {
+				return f.Format, true
+			}
+		}
+	}
+	return Format{}, false
+}
+
+// Limits bound the images accepted in one upload.
+type Limits struct {
+	// MaxFiles is the number of images accepted at once.
+	MaxFiles int
+	// MaxBytes is the size of the largest file accepted.
+	MaxBytes int64
+	// MaxSide is the largest width or height accepted, in pixels. Images are
+	// decoded in full to be resized, so this bounds memory use.
+	MaxSide int
+	// Formats lists the names of the accepted formats, e.g. "jpeg".
+	Formats []string
+}
+
+var DefaultLimits = Limits{
+	MaxFiles: 20,
+	MaxBytes: 25 << 20,
+	MaxSide:  12000,
+	Formats:  []string{"jpeg", "png"},
+}
+
+// ParseFormats reads a comma separated list of format names.
+func ParseFormats(s string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		known := false
+		for _, f := range formats {
+			known = known || f.Name == name
+		}
+		if !known {
+			return nil, fmt.Errorf("Unknown image format %q", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// FileError is why an uploaded file was rejected.
+type FileError struct {
+	Name string
+	Err  error
+}
+
+// ValidationError lists the files rejected by CheckFiles.
+type ValidationError struct {
+	Files []FileError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Files))
+	for _, f := range e.Files {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", f.Name, f.Err))
+	}
+	return "invalid images: " + strings.Join(msgs, "; ")
+}
+
+// CheckFiles checks every file against limits without decoding more than the
+// image headers, and returns a *ValidationError listing all rejected files.
+func CheckFiles(files []*multipart.FileHeader, limits Limits) error {
+	var rejected []FileError
+	for i, file := range files {
+		var err error
+		if limits.MaxFiles > 0 && i >= limits.MaxFiles {
+			err = fmt.Errorf("too many images, at most %d are accepted", limits.MaxFiles)
+		} else {
+			_, err = checkFile(file, limits)
+		}
+		if err != nil {
+			rejected = append(rejected, FileError{Name: file.Filename, Err: err})
+		}
+	}
+	if len(rejected) > 0 {
+		return &ValidationError{Files: rejected}
+	}
+	return nil
+}
+
+func checkFile(file *multipart.FileHeader, limits Limits) (Format, error) {
+	if limits.MaxBytes > 0 && file.Size > limits.MaxBytes {
+		return Format{}, fmt.Errorf("file is larger than %d bytes", limits.MaxBytes)
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return Format{}, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 16)
+	n, err := io.ReadFull(f, header)
+	if errors.Is(err, io.EOF) {
+		return Format{}, errors.New("file is empty")
+	}
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return Format{}, err
+	}
+	format, ok := Sniff(header[:n])
+	if !ok {
+		return Format{}, errors.New("file is not a supported image")
+	}
+	allowed := false
+	for _, name := range limits.Formats {
+		allowed = allowed || name == format.Name
+	}
+	if !allowed {
+		return Format{}, fmt.Errorf("%s images are not accepted", format.Name)
+	}
+
+	config, _, err := image.DecodeConfig(io.MultiReader(bytes.NewReader(header[:n]), f))
+	if err != nil {
+		return Format{}, fmt.Errorf("image cannot be read: %v", err)
+	}
+	if limits.MaxSide > 0 && (config.Width > limits.MaxSide || config.Height > limits.MaxSide) {
+		return Format{}, fmt.Errorf("image is %dx%d pixels, more than %d on a side", config.Width, config.Height, limits.MaxSide)
+	}
+	return format, nil
+}
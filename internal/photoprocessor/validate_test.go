@@ -0,0 +1,161 @@
+package photoprocessor
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		header []byte
+		want   string
+	}{
+		{header: []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00}, want: "jpeg"},
+		{header: []byte("\x89PNG\r\n\x1a\n\x00\x00"), want: "png"},
+		{header: []byte("GIF87a..."), want: "gif"},
+		{header: []byte("GIF89a..."), want: "gif"},
+		{header: []byte("II*\x00...."), want: "tiff"},
+		{header: []byte("MM\x00*...."), want: "tiff"},
+		{header: []byte("BM......"), want: "bmp"},
+		{header: []byte("<html><body>")},
+		{header: []byte("GIF88a...")},
+		// Too short to hold the magic bytes.
+		{header: []byte{0xFF, 0xD8}},
+		{header: []byte("\x89PNG")},
+		{header: nil},
+	}
+
+	for _, tt := range tests {
+		format, ok := Sniff(tt.header)
+		if ok != (tt.want != "") || format.Name != tt.want {
+			t.Errorf("Sniff(%q) = %q, %v; want %q", tt.header, format.Name, ok, tt.want)
+		}
+	}
+}
+
+type testFile struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+// formFiles returns the headers of files sent in a multipart form.
+func formFiles(t *testing.T, files ...testFile) []*multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, f := range files {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", `form-data; name="images"; filename="`+f.name+`"`)
+		header.Set("Content-Type", f.contentType)
+		part, err := w.CreatePart(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = part.Write(f.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form, err := multipart.NewReader(&body, w.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	return form.File["images"]
+}
+
+func encoded(t *testing.T, encode func(*bytes.Buffer, image.Image) error, width, height int) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	err := encode(&b, image.NewRGBA(image.Rect(0, 0, width, height)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestCheckFiles(t *testing.T) {
+	jpegData := encoded(t, func(b *bytes.Buffer, img image.Image) error { return jpeg.Encode(b, img, nil) }, 64, 48)
+	pngData := encoded(t, func(b *bytes.Buffer, img image.Image) error { return png.Encode(b, img) }, 64, 48)
+	gifData := encoded(t, func(b *bytes.Buffer, img image.Image) error { return gif.Encode(b, img, nil) }, 64, 48)
+	limits := Limits{MaxFiles: 3, MaxBytes: 1 << 20, MaxSide: 100, Formats: []string{"jpeg", "png"}}
+
+	tests := []struct {
+		name   string
+		file   testFile
+		limits *Limits
+		err    string
+	}{
+		{name: "jpeg", file: testFile{"a.jpg", "image/jpeg", jpegData}},
+		{name: "png", file: testFile{"a.png", "image/png", pngData}},
+		// The content decides, not the name or content type the client sent.
+		{name: "jpeg named png", file: testFile{"a.png", "image/png", jpegData}},
+		{name: "html named jpeg", file: testFile{"a.jpg", "image/jpeg", []byte("<html><script>alert(1)</script></html>")}, err: "not a supported image"},
+		{name: "gif named jpeg", file: testFile{"a.jpg", "image/jpeg", gifData}, err: "gif images are not accepted"},
+		{name: "empty", file: testFile{"a.jpg", "image/jpeg", nil}, err: "file is empty"},
+		{name: "too short to sniff", file: testFile{"a.jpg", "image/jpeg", []byte{0xFF, 0xD8}}, err: "not a supported image"},
+		{name: "only magic bytes", file: testFile{"a.jpg", "image/jpeg", []byte{0xFF, 0xD8, 0xFF}}, err: "cannot be read"},
+		{name: "truncated", file: testFile{"a.png", "image/png", pngData[:20]}, err: "cannot be read"},
+		{name: "oversize", file: testFile{"a.jpg", "image/jpeg", jpegData}, limits: &Limits{MaxBytes: int64(len(jpegData) - 1), Formats: []string{"jpeg"}}, err: "larger than"},
+		{name: "too many pixels", file: testFile{"a.jpg", "image/jpeg", jpegData}, limits: &Limits{MaxSide: 50, Formats: []string{"jpeg"}}, err: "64x48 pixels"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := limits
+			if tt.limits != nil {
+				l = *tt.limits
+			}
+			err := CheckFiles(formFiles(t, tt.file), l)
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("got %v, want the file accepted", err)
+				}
+				return
+			}
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) || len(invalid.Files) != 1 || !strings.Contains(invalid.Files[0].Err.Error(), tt.err) {
+				t.Errorf("got %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCheckFilesListsEveryRejection(t *testing.T) {
+	jpegData := encoded(t, func(b *bytes.Buffer, img image.Image) error { return jpeg.Encode(b, img, nil) }, 64, 48)
+	files := formFiles(t,
+		testFile{"1.jpg", "image/jpeg", jpegData},
+		testFile{"2.jpg", "image/jpeg", []byte("not an image")},
+		testFile{"3.jpg", "image/jpeg", jpegData},
+		testFile{"4.jpg", "image/jpeg", jpegData},
+	)
+
+	err := CheckFiles(files, Limits{MaxFiles: 3, Formats: []string{"jpeg"}})
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("got %v, want a *ValidationError", err)
+	}
+	want := []string{"2.jpg: file is not a supported image", "4.jpg: too many images, at most 3 are accepted"}
+	if len(invalid.Files) != len(want) {
+		t.Fatalf("rejected %v, want %v", invalid.Files, want)
+	}
+	for i, f := range invalid.Files {
+		if got := f.Name + ": " + f.Err.Error(); got != want[i] {
+			t.Errorf("rejection %d is %q, want %q", i, got, want[i])
+		}
+	}
+}
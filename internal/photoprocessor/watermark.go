@@ -0,0 +1,213 @@
+package photoprocessor
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Positions a watermark can be placed at.
+const (
+	TopLeft     = "top-left"
+	TopRight    = "top-right"
+	BottomLeft  = "bottom-left"
+	BottomRight = "bottom-right"
+	Center      = "center"
+)
+
+// DefaultWatermarkRenditions are the renditions watermarked unless others are
+// given: the ones large enough to be worth copying.
+var DefaultWatermarkRenditions = []string{"medium", "full"}
+
+// Watermark is a logo, or a line of text, overlaid on published renditions.
+type Watermark struct {
+	// Text is drawn if there is no Logo.
+	Text string
+	Logo image.Image
+	// Position is one of TopLeft, TopRight, BottomLeft, BottomRight or
+	// Center.
+	Position string
+	// Opacity is between 0, invisible, and 1.
+	Opacity float64
+	// Scale is the largest fraction of the width and of the height of the
+	// image the watermark covers.
+	Scale float64
+	// Renditions are the names of the renditions watermarked.
+	Renditions []string
+}
+
+// LoadLogo reads the PNG image at path, keeping its transparency.
+func LoadLogo(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	logo, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid watermark logo %s: %v", path, err)
+	}
+	return logo, nil
+}
+
+// Check reports settings that cannot be applied to renditions. Originals are
+// never watermarked, as they are the copy kept to produce clean images from.
+func (w *Watermark) Check(renditions []Rendition) error {
+	if w.Logo == nil && strings.TrimSpace(w.Text) == "" {
+		return fmt.Errorf("Watermark needs a logo or a text")
+	}
+	switch w.Position {
+	case TopLeft, TopRight, BottomLeft, BottomRight, Center:
+	default:
+		return fmt.Errorf("Invalid watermark position %q", w.Position)
+	}
+	if w.Opacity <= 0 || w.Opacity > 1 {
+		return fmt.Errorf("Invalid watermark opacity %v, must be above 0 and at most 1", w.Opacity)
+	}
+	if w.Scale <= 0 || w.Scale > 1 {
+		return fmt.Errorf("Invalid watermark scale %v, must be above 0 and at most 1", w.Scale)
+	}
+	if w.Logo == nil {
+		face := textFace()
+		defer face.Close()
+		for _, r := range w.Text {
+			if _, ok := face.GlyphAdvance(r); !ok {
+				return fmt.Errorf("Watermark text %q has a character the font cannot draw: %q", w.Text, r)
+			}
+		}
+	}
+
+	sizes := make(map[string]int, len(renditions))
+	for _, r := range renditions {
+		sizes[r.Name] = r.LongSide
+	}
+	for _, name := range w.Renditions {
+		size, ok := sizes[name]
+		if !ok {
+			return fmt.Errorf("Watermarked rendition %q is not stored", name)
+		}
+		if size == 0 {
+			return fmt.Errorf("Rendition %q is an original and cannot be watermarked", name)
+		}
+	}
+	return nil
+}
+
+// Applies reports whether the rendition named rendition is watermarked.
+func (w *Watermark) Applies(rendition string) bool {
+	if w == nil {
+		return false
+	}
+	for _, name := range w.Renditions {
+		if name == rendition {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns img with the watermark overlaid.
+func (w *Watermark) Apply(img image.Image) *image.NRGBA {
+	mark := w.mark()
+	bounds := img.Bounds()
+
+	// The watermark keeps its aspect ratio, so it is scaled to the smaller
+	// of the two factors that fit it in the box.
+	maxWidth := w.Scale * float64(bounds.Dx())
+	maxHeight := w.Scale * float64(bounds.Dy())
+	factor := min(maxWidth/float64(mark.Bounds().Dx()), maxHeight/float64(mark.Bounds().Dy()))
+	width := max(1, int(factor*float64(mark.Bounds().Dx())))
+	height := max(1, int(factor*float64(mark.Bounds().Dy())))
+	mark = imaging.Resize(mark, width, height, imaging.Linear)
+
+	margin := min(bounds.Dx(), bounds.Dy()) / 50
+	left, top := bounds.Min.X+margin, bounds.Min.Y+margin
+	right, bottom := bounds.Max.X-margin-width, bounds.Max.Y-margin-height
+	var at image.Point
+	switch w.Position {
+	case TopLeft:
+		at = image.Pt(left, top)
+	case TopRight:
+		at = image.Pt(right, top)
+	case BottomLeft:
+		at = image.Pt(left, bottom)
+	case Center:
+		at = image.Pt(bounds.Min.X+(bounds.Dx()-width)/2, bounds.Min.Y+(bounds.Dy()-height)/2)
+	default:
+		at = image.Pt(right, bottom)
+	}
+
+	return imaging.Overlay(img, mark, at, w.Opacity)
+}
+
+func (w *Watermark) mark() *image.NRGBA {
+	if w.Logo != nil {
+		return imaging.Clone(w.Logo)
+	}
+	return textMark(w.Text)
+}
+
+// textFont draws text watermarks. Unlike the basic bitmap fonts, the Go
+// fonts cover Cyrillic, so that Ukrainian names can be drawn too.
+var textFont = func() *opentype.Font {
+	f, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}()
+
+// textSize is the size text watermarks are drawn at before Apply scales
+// them, large enough not to blur when scaled up.
+const textSize = 48
+
+// textFace returns a face of textFont. Faces are not safe for concurrent
+// use, so every caller gets its own.
+func textFace() font.Face {
+	face, err := opentype.NewFace(textFont, &opentype.FaceOptions{Size: textSize, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		panic(err)
+	}
+	return face
+}
+
+// textMark draws text in white over a dark shadow, so that it shows on light
+// and dark images alike. It is scaled to size by Apply.
+func textMark(text string) *image.NRGBA {
+	face := textFace()
+	defer face.Close()
+	metrics := face.Metrics()
+	drawer := font.Drawer{Face: face}
+	shadow := textSize / 24
+	width := drawer.MeasureString(text).Ceil() + shadow
+	height := (metrics.Ascent + metrics.Descent).Ceil() + shadow
+
+	mark := image.NewNRGBA(image.Rect(0, 0, width, height))
+	drawer.Dst = mark
+	drawer.Src = image.NewUniform(color.NRGBA{A: 160})
+	drawer.Dot = fixed.P(shadow, metrics.Ascent.Ceil()+shadow)
+	drawer.DrawString(text)
+	drawer.Src = image.White
+	drawer.Dot = fixed.P(0, metrics.Ascent.Ceil())
+	drawer.DrawString(text)
+	return mark
+}
+
+// ApplyWatermark overlays w on the image at path, in place.
+func ApplyWatermark(path string, w *Watermark) error {
+	img, err := imaging.Open(path)
+	if err != nil {
+		return err
+	}
+	return imaging.Save(w.Apply(img), path)
+}
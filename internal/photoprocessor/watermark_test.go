@@ -0,0 +1,57 @@
+package photoprocessor
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestWatermarkCheckText(t *testing.T) {
+	tests := []struct {
+		text string
+		ok   bool
+	}{
+		{text: "© Gallery 2024", ok: true},
+		{text: "© Галерея Ґанок", ok: true},
+		{text: "画廊"},
+		{text: "Gallery 🎨"},
+	}
+
+	for _, tt := range tests {
+		w := Watermark{Text: tt.text, Position: BottomRight, Opacity: 0.5, Scale: 0.3}
+		err := w.Check(DefaultRenditions)
+		if tt.ok && err != nil {
+			t.Errorf("Check(%q): %v", tt.text, err)
+		}
+		if !tt.ok && (err == nil || !strings.Contains(err.Error(), "cannot draw")) {
+			t.Errorf("Check(%q) = %v, want an error about the font", tt.text, err)
+		}
+	}
+}
+
+func TestWatermarkApplyCyrillicText(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 400, 300))
+	for i := range img.Pix {
+		img.Pix[i] = 40
+	}
+	w := &Watermark{Text: "Галерея", Position: Center, Opacity: 1, Scale: 0.5}
+
+	out := w.Apply(img)
+	if out.Bounds() != img.Bounds() {
+		t.Fatalf("watermarked image is %v, want %v", out.Bounds(), img.Bounds())
+	}
+
+	// The text is drawn in white: some pixels near the center turn light.
+	light := 0
+	for y := 100; y < 200; y++ {
+		for x := 100; x < 300; x++ {
+			if c := color.GrayModel.Convert(out.At(x, y)).(color.Gray); c.Y > 200 {
+				light++
+			}
+		}
+	}
+	if light == 0 {
+		t.Error("no text was drawn")
+	}
+}
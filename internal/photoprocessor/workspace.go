@@ -0,0 +1,105 @@
+package photoprocessor
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const workspacePrefix = "art-upload-"
+
+// Workspace is a directory private to one upload, holding the received
+// originals and their resized copies.
+type Workspace struct {
+	Dir string
+}
+
+// NewWorkspace creates a workspace in root, or in the system temporary
+// directory if root is empty.
+func NewWorkspace(root string) (*Workspace, error) {
+	if root != "" {
+		err := os.MkdirAll(root, 0755)
+		if err != nil {
+			return nil, err
+		}
+	}
+	dir, err := os.MkdirTemp(root, workspacePrefix+"*")
+	if err != nil {
+		return nil, err
+	}
+	return &Workspace{Dir: dir}, nil
+}
+
+// Originals is where the received files are saved.
+func (w *Workspace) Originals() string {
+	return filepath.Join(w.Dir, "original")
+}
+
+// Resized is where the resized files are written.
+func (w *Workspace) Resized() string {
+	return filepath.Join(w.Dir, "resized")
+}
+
+// Remove deletes the workspace and everything in it.
+func (w *Workspace) Remove() error {
+	return os.RemoveAll(w.Dir)
+}
+
+// ListWorkspaces returns the workspaces in root.
+func ListWorkspaces(root string) ([]*Workspace, error) {
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var workspaces []*Workspace
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), workspacePrefix) {
+			workspaces = append(workspaces, &Workspace{Dir: filepath.Join(root, entry.Name())})
+		}
+	}
+	return workspaces, nil
+}
+
+// SweepWorkspaces removes the workspaces in root, or in the system temporary
+// directory if root is empty, last modified more than maxAge ago. They were
+// left behind by a server that crashed mid-upload. It returns how many were
+// removed.
+func SweepWorkspaces(root string, maxAge time.Duration) (int, error) {
+	if root == "" {
+		root = os.TempDir()
+	}
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	var errs []error
+	cutoff := time.Now().Add(-maxAge)
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), workspacePrefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		err = os.RemoveAll(filepath.Join(root, entry.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+	}
+	return removed, errors.Join(errs...)
+}
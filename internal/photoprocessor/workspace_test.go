@@ -0,0 +1,73 @@
+package photoprocessor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// backdate sets the modification time of path to age ago.
+func backdate(t *testing.T, path string, age time.Duration) {
+	t.Helper()
+	at := time.Now().Add(-age)
+	err := os.Chtimes(path, at, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSweepWorkspaces(t *testing.T) {
+	root := t.TempDir()
+	workspace := func(age time.Duration) string {
+		ws, err := NewWorkspace(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.MkdirAll(ws.Originals(), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		backdate(t, ws.Dir, age)
+		return ws.Dir
+	}
+	stale := workspace(7 * time.Hour)
+	staler := workspace(30 * 24 * time.Hour)
+	recent := workspace(5 * time.Hour)
+	fresh := workspace(0)
+
+	// Only workspaces are swept, whatever their age.
+	other := filepath.Join(root, "photos")
+	err := os.Mkdir(other, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backdate(t, other, 30*24*time.Hour)
+	file := filepath.Join(root, workspacePrefix+"file")
+	err = os.WriteFile(file, nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backdate(t, file, 30*24*time.Hour)
+
+	removed, err := SweepWorkspaces(root, 6*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Errorf("removed %d workspaces, want 2", removed)
+	}
+	for path, kept := range map[string]bool{stale: false, staler: false, recent: true, fresh: true, other: true, file: true} {
+		_, err := os.Stat(path)
+		if exists := err == nil; exists != kept {
+			t.Errorf("%s exists: %v, want %v", filepath.Base(path), exists, kept)
+		}
+	}
+}
+
+func TestSweepWorkspacesMissingRoot(t *testing.T) {
+	removed, err := SweepWorkspaces(filepath.Join(t.TempDir(), "missing"), time.Hour)
+	if removed != 0 || err != nil {
+		t.Errorf("got %d, %v; want nothing removed", removed, err)
+	}
+}
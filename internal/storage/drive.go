@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"art/internal/drive"
+	"context"
+	"io"
+)
+
+// DriveStore keeps images in Google Drive. Folders are referenced by their
+// Drive IDs and images are served by Drive.
+type DriveStore struct {
+	client *drive.Client
+}
+
+func NewDriveStore(client *drive.Client) *DriveStore {
+	return &DriveStore{client: client}
+}
+
+func (s *DriveStore) Put(ctx context.Context, folder string, name string, content io.Reader) (Object, error) {
+	folderID, fileID, err := s.client.UploadFile(ctx, folder, name, content)
+	if err != nil {
+		return Object{}, err
+	}
+	return Object{Folder: folderID, Key: fileID}, nil
+}
+
+func (s *DriveStore) Find(ctx context.Context, folder string, name string) (Object, bool, error) {
+	folderID, fileID, found, err := s.client.FindFile(ctx, folder, name)
+	if err != nil || !found {
+		return Object{}, false, err
+	}
+	return Object{Folder: folderID, Key: fileID}, true, nil
+}
+
+func (s *DriveStore) Folders(ctx context.Context) ([]Folder, error) {
+	folders, err := s.client.Folders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Folder, 0, len(folders))
+	for _, f := range folders {
+		list = append(list, Folder{Name: f.ID, Label: f.Name, Created: f.Created})
+	}
+	return list, nil
+}
+
+func (s *DriveStore) Delete(ctx context.Context, folder string) error {
+	return s.client.DeleteFolder(ctx, folder)
+}
+
+func (s *DriveStore) URL(ctx context.Context, obj Object) (string, error) {
+	return s.client.FileURL(ctx, obj.Key)
+}
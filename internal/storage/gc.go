@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Folder is an image folder. Name is the reference kept in
+// models.Photos.FolderId.
+type Folder struct {
+	Name string
+	// Label is the name the folder was created with, for stores whose
+	// references are IDs rather than names.
+	Label   string
+	Created time.Time
+}
+
+// Lister is implemented by stores that can enumerate their folders.
+type Lister interface {
+	Folders(ctx context.Context) ([]Folder, error)
+}
+
+// Reference is an image folder referenced by a painting, artist, collection
+// or photo job.
+type Reference struct {
+	Kind   string
+	ID     string
+	Folder string
+	// Pending references are the folders unfinished jobs store images in,
+	// by the name they create them with. They keep the folder from being
+	// deleted, but are not dangling as it may not have been created yet.
+	Pending bool
+}
+
+// Report is the outcome of Audit.
+type Report struct {
+	// Orphans are folders nothing references.
+	Orphans []Folder
+	// Dangling are references to folders that do not exist.
+	Dangling []Reference
+	// Recent counts orphans skipped because they are younger than the grace
+	// period, as they may belong to an upload still in progress.
+	Recent int
+}
+
+// Audit compares the folders of store with refs. Folders created less than
+// grace ago are never reported as orphans.
+func Audit(ctx context.Context, store Lister, refs []Reference, grace time.Duration) (Report, error) {
+	folders, err := store.Folders(ctx)
+	if err != nil {
+		return Report{}, err
+	}
+
+	referenced := make(map[string]bool, len(refs))
+	for _, ref := range refs {
+		referenced[ref.Folder] = true
+	}
+	existing := make(map[string]bool, len(folders))
+
+	var report Report
+	cutoff := time.Now().Add(-grace)
+	for _, f := range folders {
+		existing[f.Name] = true
+		switch {
+		case referenced[f.Name], f.Label != "" && referenced[f.Label]:
+		case f.Created.After(cutoff):
+			report.Recent++
+		default:
+			report.Orphans = append(report.Orphans, f)
+		}
+	}
+	for _, ref := range refs {
+		if !ref.Pending && !existing[ref.Folder] {
+			report.Dangling = append(report.Dangling, ref)
+		}
+	}
+
+	sort.Slice(report.Orphans, func(i, j int) bool { return report.Orphans[i].Name < report.Orphans[j].Name })
+	return report, nil
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// folderList is a Lister of fixed folders.
+type folderList []Folder
+
+func (l folderList) Folders(ctx context.Context) ([]Folder, error) {
+	return l, nil
+}
+
+func TestAudit(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+	folders := folderList{
+		{Name: "painting-1", Created: old},
+		{Name: "painting-2", Created: old},
+		{Name: "painting-3", Created: time.Now()},
+		// Drive folders are referenced by ID, but jobs know them by name.
+		{Name: "1a2b", Label: "painting-4", Created: old},
+		{Name: "3c4d", Label: "painting-5", Created: old},
+	}
+	refs := []Reference{
+		{Kind: "painting", ID: "p1", Folder: "painting-1"},
+		{Kind: "painting", ID: "p6", Folder: "painting-6"},
+		{Kind: "job", ID: "j4", Folder: "painting-4", Pending: true},
+		{Kind: "job", ID: "j7", Folder: "painting-7", Pending: true},
+	}
+
+	report, err := Audit(context.Background(), folders, refs, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var orphans []string
+	for _, f := range report.Orphans {
+		orphans = append(orphans, f.Name)
+	}
+	if len(orphans) != 2 || orphans[0] != "3c4d" || orphans[1] != "painting-2" {
+		t.Errorf("orphans %v, want [3c4d painting-2]", orphans)
+	}
+	// The folder of a pending job may not be created yet.
+	if len(report.Dangling) != 1 || report.Dangling[0].ID != "p6" {
+		t.Errorf("dangling %+v, want only painting p6", report.Dangling)
+	}
+	if report.Recent != 1 {
+		t.Errorf("%d recent folders, want 1", report.Recent)
+	}
+}
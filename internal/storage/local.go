@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore keeps images on the local disk under Root and serves them from
+// BaseURL, which must point at Handler.
+type LocalStore struct {
+	Root    string
+	BaseURL string
+}
+
+func NewLocalStore(root string, baseURL string) (*LocalStore, error) {
+	err := os.MkdirAll(root, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalStore{Root: root, BaseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+func (s *LocalStore) Put(ctx context.Context, folder string, name string, content io.Reader) (Object, error) {
+	if !validName(folder) || !validName(name) {
+		return Object{}, fmt.Errorf("invalid image path %q/%q", folder, name)
+	}
+
+	dir := filepath.Join(s.Root, folder)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return Object{}, err
+	}
+
+	// Write to a temporary file first, so that Find never sees a partial
+	// image.
+	f, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return Object{}, err
+	}
+	_, err = io.Copy(f, content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(dir, name))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return Object{}, err
+	}
+
+	return Object{Folder: folder, Key: path.Join(folder, name)}, nil
+}
+
+func (s *LocalStore) Find(ctx context.Context, folder string, name string) (Object, bool, error) {
+	if !validName(folder) || !validName(name) {
+		return Object{}, false, fmt.Errorf("invalid image path %q/%q", folder, name)
+	}
+
+	info, err := os.Stat(filepath.Join(s.Root, folder, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Object{}, false, nil
+	}
+	if err != nil {
+		return Object{}, false, err
+	}
+	if !info.Mode().IsRegular() {
+		return Object{}, false, nil
+	}
+	return Object{Folder: folder, Key: path.Join(folder, name)}, true, nil
+}
+
+func (s *LocalStore) Folders(ctx context.Context) ([]Folder, error) {
+	entries, err := os.ReadDir(s.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	var folders []Folder
+	for _, entry := range entries {
+		if !entry.IsDir() || !validName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		folders = append(folders, Folder{Name: entry.Name(), Created: info.ModTime()})
+	}
+	return folders, nil
+}
+
+// Delete removes folder. Deleting a folder that does not exist succeeds.
+func (s *LocalStore) Delete(ctx context.Context, folder string) error {
+	if !validName(folder) {
+		return fmt.Errorf("invalid image folder %q", folder)
+	}
+	return os.RemoveAll(filepath.Join(s.Root, folder))
+}
+
+func (s *LocalStore) URL(ctx context.Context, obj Object) (string, error) {
+	return s.BaseURL + "/" + escapeKey(obj.Key), nil
+}
+
+// Handler serves the stored images, without directory listings.
+func (s *LocalStore) Handler() http.Handler {
+	files := http.FileServer(http.Dir(s.Root))
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "/") {
+			http.NotFound(res, req)
+			return
+		}
+		files.ServeHTTP(res, req)
+	})
+}
+
+// validName reports whether name is a single, non-special path element.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
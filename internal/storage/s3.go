@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Config describes an S3-compatible bucket. Credentials are read from the
+// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY or MINIO_ROOT_USER and
+// MINIO_ROOT_PASSWORD environment variables.
+type S3Config struct {
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix is prepended to every key, so that several galleries can share
+	// a bucket.
+	Prefix string
+	Secure bool
+	// Public objects are uploaded with a public-read ACL and linked to
+	// directly. Private objects are linked to through Handler, which
+	// redirects to a short-lived presigned URL.
+	Public bool
+	// PublicURL replaces the endpoint and bucket in links to public objects,
+	// for buckets served through a CDN.
+	PublicURL string
+	// BaseURL is where Handler is served for private objects.
+	BaseURL string
+	// URLExpiry is how long presigned URLs are valid for.
+	URLExpiry time.Duration
+}
+
+// S3Store keeps images in an S3-compatible bucket under one key prefix per
+// folder.
+type S3Store struct {
+	client *minio.Client
+	config S3Config
+}
+
+func NewS3Store(ctx context.Context, config S3Config) (*S3Store, error) {
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds: credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+		}),
+		Secure: config.Secure,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := client.BucketExists(ctx, config.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("Could not reach bucket %s: %v", config.Bucket, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("Bucket %s does not exist", config.Bucket)
+	}
+
+	if config.Prefix != "" && !strings.HasSuffix(config.Prefix, "/") {
+		config.Prefix += "/"
+	}
+	config.PublicURL = strings.TrimSuffix(config.PublicURL, "/")
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	if config.URLExpiry <= 0 {
+		config.URLExpiry = 15 * time.Minute
+	}
+
+	return &S3Store{client: client, config: config}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, folder string, name string, content io.Reader) (Object, error) {
+	if !validName(folder) || !validName(name) {
+		return Object{}, fmt.Errorf("invalid image path %q/%q", folder, name)
+	}
+
+	size := int64(-1)
+	if f, ok := content.(interface{ Stat() (os.FileInfo, error) }); ok {
+		if info, err := f.Stat(); err == nil {
+			size = info.Size()
+		}
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	opts := minio.PutObjectOptions{ContentType: contentType}
+	if s.config.Public {
+		opts.UserMetadata = map[string]string{"x-amz-acl": "public-read"}
+	}
+
+	key := path.Join(folder, name)
+	_, err := s.client.PutObject(ctx, s.config.Bucket, s.config.Prefix+key, content, size, opts)
+	if err != nil {
+		return Object{}, err
+	}
+
+	return Object{Folder: folder, Key: key}, nil
+}
+
+func (s *S3Store) Find(ctx context.Context, folder string, name string) (Object, bool, error) {
+	if !validName(folder) || !validName(name) {
+		return Object{}, false, fmt.Errorf("invalid image path %q/%q", folder, name)
+	}
+
+	key := path.Join(folder, name)
+	_, err := s.client.StatObject(ctx, s.config.Bucket, s.config.Prefix+key, minio.StatObjectOptions{})
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return Object{}, false, nil
+	}
+	if err != nil {
+		return Object{}, false, err
+	}
+	return Object{Folder: folder, Key: key}, true, nil
+}
+
+// Folders lists the folders under the prefix. As S3 has no folders, a
+// folder is as old as its oldest object.
+func (s *S3Store) Folders(ctx context.Context) ([]Folder, error) {
+	created := make(map[string]time.Time)
+	for obj := range s.client.ListObjects(ctx, s.config.Bucket, minio.ListObjectsOptions{
+		Prefix:    s.config.Prefix,
+		Recursive: true,
+	}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		folder, _, ok := strings.Cut(strings.TrimPrefix(obj.Key, s.config.Prefix), "/")
+		if !ok || !validName(folder) {
+			continue
+		}
+		if t, seen := created[folder]; !seen || obj.LastModified.Before(t) {
+			created[folder] = obj.LastModified
+		}
+	}
+
+	folders := make([]Folder, 0, len(created))
+	for name, t := range created {
+		folders = append(folders, Folder{Name: name, Created: t})
+	}
+	return folders, nil
+}
+
+// Delete removes every object under the folder prefix.
+func (s *S3Store) Delete(ctx context.Context, folder string) error {
+	if !validName(folder) {
+		return fmt.Errorf("invalid image folder %q", folder)
+	}
+
+	objects := s.client.ListObjects(ctx, s.config.Bucket, minio.ListObjectsOptions{
+		Prefix:    s.config.Prefix + folder + "/",
+		Recursive: true,
+	})
+
+	var errs []error
+	for removeErr := range s.client.RemoveObjects(ctx, s.config.Bucket, objects, minio.RemoveObjectsOptions{}) {
+		errs = append(errs, fmt.Errorf("%s: %v", removeErr.ObjectName, removeErr.Err))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *S3Store) URL(ctx context.Context, obj Object) (string, error) {
+	escaped := escapeKey(obj.Key)
+	if !s.config.Public {
+		return s.config.BaseURL + "/" + escaped, nil
+	}
+	if s.config.PublicURL != "" {
+		return s.config.PublicURL + "/" + escapeKey(s.config.Prefix+obj.Key), nil
+	}
+
+	endpoint := s.client.EndpointURL()
+	return fmt.Sprintf("%s://%s/%s/%s", endpoint.Scheme, endpoint.Host, s.config.Bucket, escapeKey(s.config.Prefix+obj.Key)), nil
+}
+
+// Handler redirects requests for private objects, relative to BaseURL, to
+// presigned URLs.
+func (s *S3Store) Handler() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		folder, name := path.Split(strings.TrimPrefix(req.URL.Path, "/"))
+		folder = strings.TrimSuffix(folder, "/")
+		if !validName(folder) || !validName(name) {
+			http.NotFound(res, req)
+			return
+		}
+
+		signed, err := s.client.PresignedGetObject(req.Context(), s.config.Bucket, s.config.Prefix+path.Join(folder, name), s.config.URLExpiry, nil)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadGateway)
+			return
+		}
+		http.Redirect(res, req, signed.String(), http.StatusFound)
+	})
+}
+
+func escapeKey(key string) string {
+	parts := strings.Split(key, "/")
+	for i := range parts {
+		parts[i] = url.PathEscape(parts[i])
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,318 @@
+package storage
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is an S3 stand-in serving one bucket with the requests S3Store
+// makes, ignoring authentication.
+type fakeS3 struct {
+	bucket string
+
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data     []byte
+	modified time.Time
+}
+
+type fakeListResult struct {
+	XMLName     xml.Name `xml:"ListBucketResult"`
+	Name        string
+	Prefix      string
+	KeyCount    int
+	MaxKeys     int
+	IsTruncated bool
+	Contents    []fakeListEntry
+}
+
+type fakeListEntry struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int
+}
+
+type fakeDelete struct {
+	Objects []struct {
+		Key string
+	} `xml:"Object"`
+}
+
+func (s *fakeS3) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/"), "/")
+	if bucket != s.bucket {
+		res.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case key == "" && req.Method == http.MethodHead:
+		res.WriteHeader(http.StatusOK)
+
+	case key == "" && req.Method == http.MethodGet:
+		prefix := req.URL.Query().Get("prefix")
+		result := fakeListResult{Name: s.bucket, Prefix: prefix, MaxKeys: 1000}
+		for k, obj := range s.objects {
+			if strings.HasPrefix(k, prefix) {
+				result.Contents = append(result.Contents, fakeListEntry{
+					Key:          k,
+					LastModified: obj.modified.UTC().Format("2006-01-02T15:04:05.000Z"),
+					ETag:         `"etag"`,
+					Size:         len(obj.data),
+				})
+			}
+		}
+		sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+		result.KeyCount = len(result.Contents)
+		res.Header().Set("Content-Type", "application/xml")
+		xml.NewEncoder(res).Encode(result)
+
+	case key == "" && req.Method == http.MethodPost && req.URL.Query().Has("delete"):
+		var del fakeDelete
+		err := xml.NewDecoder(req.Body).Decode(&del)
+		if err != nil {
+			res.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, obj := range del.Objects {
+			delete(s.objects, obj.Key)
+		}
+		res.Header().Set("Content-Type", "application/xml")
+		io.WriteString(res, `<DeleteResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"></DeleteResult>`)
+
+	case req.Method == http.MethodPut:
+		data, err := io.ReadAll(req.Body)
+		if err != nil {
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		s.objects[key] = fakeObject{data: data, modified: time.Now()}
+		res.Header().Set("ETag", `"etag"`)
+		res.WriteHeader(http.StatusOK)
+
+	case req.Method == http.MethodHead:
+		obj, ok := s.objects[key]
+		if !ok {
+			res.WriteHeader(http.StatusNotFound)
+			return
+		}
+		res.Header().Set("ETag", `"etag"`)
+		res.Header().Set("Last-Modified", obj.modified.UTC().Format(http.TimeFormat))
+		res.Header().Set("Content-Length", fmt.Sprint(len(obj.data)))
+		res.WriteHeader(http.StatusOK)
+
+	default:
+		res.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func (s *fakeS3) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for k := range s.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func newTestS3Store(t *testing.T, config S3Config) (*S3Store, *fakeS3) {
+	t.Helper()
+	t.Setenv("AWS_ACCESS_KEY_ID", "access")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	fake := &fakeS3{bucket: "gallery", objects: make(map[string]fakeObject)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	config.Endpoint = strings.TrimPrefix(server.URL, "http://")
+	config.Region = "us-east-1"
+	if config.Bucket == "" {
+		config.Bucket = fake.bucket
+	}
+	store, err := NewS3Store(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, fake
+}
+
+// putTestFile stores content as name in folder from a file, like storeFiles.
+func putTestFile(t *testing.T, store ImageStore, folder string, name string, content string) Object {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	obj, err := store.Put(context.Background(), folder, name, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestS3StoreMissingBucket(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "access")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	server := httptest.NewServer(&fakeS3{bucket: "gallery"})
+	defer server.Close()
+
+	_, err := NewS3Store(context.Background(), S3Config{
+		Endpoint: strings.TrimPrefix(server.URL, "http://"),
+		Region:   "us-east-1",
+		Bucket:   "other",
+	})
+	if err == nil {
+		t.Fatal("NewS3Store succeeded for a missing bucket")
+	}
+}
+
+func TestS3StorePutFindDelete(t *testing.T) {
+	ctx := context.Background()
+	store, fake := newTestS3Store(t, S3Config{Prefix: "art"})
+
+	obj := putTestFile(t, store, "painting-1", "0-large.jpg", "image")
+	putTestFile(t, store, "painting-1", "0-thumb.jpg", "thumb")
+	putTestFile(t, store, "painting-2", "0-large.jpg", "image")
+	if obj != (Object{Folder: "painting-1", Key: "painting-1/0-large.jpg"}) {
+		t.Errorf("Put returned %+v", obj)
+	}
+	want := []string{"art/painting-1/0-large.jpg", "art/painting-1/0-thumb.jpg", "art/painting-2/0-large.jpg"}
+	if got := fake.keys(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("keys %v, want %v", got, want)
+	}
+
+	found, ok, err := store.Find(ctx, "painting-1", "0-large.jpg")
+	if err != nil || !ok || found != obj {
+		t.Errorf("Find = %+v, %v, %v; want %+v", found, ok, err, obj)
+	}
+	_, ok, err = store.Find(ctx, "painting-1", "1-large.jpg")
+	if err != nil || ok {
+		t.Errorf("Find of a missing image = %v, %v", ok, err)
+	}
+
+	folders, err := store.Folders(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range folders {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "painting-1,painting-2" {
+		t.Errorf("Folders = %v", names)
+	}
+
+	err = store.Delete(ctx, "painting-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.keys(); len(got) != 1 || got[0] != "art/painting-2/0-large.jpg" {
+		t.Errorf("keys after Delete: %v", got)
+	}
+
+	_, err = store.Put(ctx, "../escape", "x.jpg", strings.NewReader("x"))
+	if err == nil {
+		t.Error("Put accepted an invalid folder")
+	}
+}
+
+func TestS3StoreURL(t *testing.T) {
+	ctx := context.Background()
+	obj := Object{Folder: "painting-1", Key: "painting-1/0 large.jpg"}
+
+	tests := []struct {
+		name   string
+		config S3Config
+		want   string
+	}{
+		{
+			name:   "private",
+			config: S3Config{BaseURL: "https://art.example/images/"},
+			want:   "https://art.example/images/painting-1/0%20large.jpg",
+		},
+		{
+			name:   "public CDN",
+			config: S3Config{Public: true, Prefix: "art", PublicURL: "https://cdn.example/"},
+			want:   "https://cdn.example/art/painting-1/0%20large.jpg",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, _ := newTestS3Store(t, tt.config)
+			got, err := store.URL(ctx, obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("URL = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("public endpoint", func(t *testing.T) {
+		store, _ := newTestS3Store(t, S3Config{Public: true})
+		got, err := store.URL(ctx, obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		endpoint := store.client.EndpointURL()
+		if want := "http://" + endpoint.Host + "/gallery/painting-1/0%20large.jpg"; got != want {
+			t.Errorf("URL = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestS3StoreHandlerRedirects(t *testing.T) {
+	store, _ := newTestS3Store(t, S3Config{Prefix: "art", URLExpiry: time.Minute})
+
+	res := httptest.NewRecorder()
+	store.Handler().ServeHTTP(res, httptest.NewRequest("GET", "/painting-1/0-large.jpg", nil))
+	if res.Code != http.StatusFound {
+		t.Fatalf("got %d, want 302", res.Code)
+	}
+	location, err := url.Parse(res.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Path != "/gallery/art/painting-1/0-large.jpg" {
+		t.Errorf("redirected to %s", location.Path)
+	}
+	if location.Query().Get("X-Amz-Signature") == "" || location.Query().Get("X-Amz-Expires") != "60" {
+		t.Errorf("redirect is not presigned for a minute: %s", location.RawQuery)
+	}
+
+	res = httptest.NewRecorder()
+	store.Handler().ServeHTTP(res, httptest.NewRequest("GET", "/painting-1/../../secret", nil))
+	if res.Code != http.StatusNotFound {
+		t.Errorf("invalid path: got %d, want 404", res.Code)
+	}
+}
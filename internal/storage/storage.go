@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"art/internal/models"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Object identifies a stored image. Folder is the reference kept in
+// models.Photos.FolderId and Key is backend specific.
+type Object struct {
+	Folder string
+	Key    string
+}
+
+// ImageStore keeps the images of paintings, artists and collections, grouped
+// in folders.
+type ImageStore interface {
+	// Put stores content as name in folder, creating the folder if needed.
+	Put(ctx context.Context, folder string, name string, content io.Reader) (Object, error)
+	// Delete removes a folder, given by the Folder of its objects, and the
+	// images in it.
+	Delete(ctx context.Context, folder string) error
+	// URL returns the address obj is served at.
+	URL(ctx context.Context, obj Object) (string, error)
+}
+
+// Server is implemented by stores whose images are served, or redirected to,
+// by the art server itself under their base URL.
+type Server interface {
+	Handler() http.Handler
+}
+
+// Finder is implemented by stores that can look up an image stored by an
+// earlier Put, so that an interrupted upload can be resumed.
+type Finder interface {
+	// Find returns the object stored as name in folder, if any.
+	Find(ctx context.Context, folder string, name string) (Object, bool, error)
+}
+
+// Result is the outcome of storing one file.
+type Result struct {
+	Name   string
+	Object Object
+	URL    string
+	Err    error
+}
+
+// UploadError is returned when some files of a folder could not be stored.
+// Results holds the outcome of every file, in upload order.
+type UploadError struct {
+	Results []Result
+}
+
+func (e *UploadError) Error() string {
+	var failed []string
+	for _, r := range e.Results {
+		if r.Err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", r.Name, r.Err))
+		}
+	}
+	return fmt.Sprintf("could not upload %d of %d images: %s", len(failed), len(e.Results), strings.Join(failed, "; "))
+}
+
+func (e *UploadError) Unwrap() []error {
+	var errs []error
+	for _, r := range e.Results {
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+		}
+	}
+	return errs
+}
+
+// Uploaded returns the folder the files that were stored went to, if any.
+func (e *UploadError) Uploaded() (string, bool) {
+	for _, r := range e.Results {
+		if r.Err == nil {
+			return r.Object.Folder, true
+		}
+	}
+	return "", false
+}
+
+// DefaultUploadWorkers is how many files UploadDir stores at once when not
+// told otherwise.
+const DefaultUploadWorkers = 4
+
+// UploadDir stores every file in dir in folder, up to workers at a time, and
+// returns their URLs in directory order. See UploadFiles.
+func UploadDir(ctx context.Context, store ImageStore, folder string, dir string, workers int) (models.Photos, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return models.Photos{}, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	results, err := UploadFiles(ctx, store, folder, paths, workers)
+	if err != nil {
+		return models.Photos{}, err
+	}
+
+	var photos models.Photos
+	for _, r := range results {
+		photos.FolderId = r.Object.Folder
+		photos.Urls = append(photos.Urls, r.URL)
+	}
+	return photos, nil
+}
+
+// UploadFiles stores the files at paths in folder, under their base names,
+// up to workers at a time, and returns the results in the order of paths.
+// The first failure cancels the files not stored yet and an *UploadError is
+// returned. Files already in folder are not uploaded again when store is a
+// Finder, so calling UploadFiles again resumes a failed upload.
+func UploadFiles(ctx context.Context, store ImageStore, folder string, paths []string, workers int) ([]Result, error) {
+	if workers <= 0 {
+		workers = DefaultUploadWorkers
+	}
+
+	results := make([]Result, len(paths))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(workers)
+	for i, path := range paths {
+		r := &results[i]
+		r.Name = filepath.Base(path)
+		path := path
+		g.Go(func() error {
+			if gctx.Err() != nil {
+				r.Err = fmt.Errorf("not uploaded: %w", gctx.Err())
+				return r.Err
+			}
+			*r = uploadFile(gctx, store, folder, path)
+			return r.Err
+		})
+	}
+	if g.Wait() != nil {
+		return nil, &UploadError{Results: results}
+	}
+	return results, nil
+}
+
+func uploadFile(ctx context.Context, store ImageStore, folder string, path string) Result {
+	r := Result{Name: filepath.Base(path)}
+
+	found := false
+	if finder, ok := store.(Finder); ok {
+		r.Object, found, r.Err = finder.Find(ctx, folder, r.Name)
+		if r.Err != nil {
+			return r
+		}
+	}
+	if !found {
+		r.Object, r.Err = putFile(ctx, store, folder, path)
+		if r.Err != nil {
+			return r
+		}
+	}
+
+	r.URL, r.Err = store.URL(ctx, r.Object)
+	return r
+}
+
+func putFile(ctx context.Context, store ImageStore, folder string, path string) (Object, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Object{}, err
+	}
+	defer f.Close()
+
+	return store.Put(ctx, folder, filepath.Base(path), f)
+}
@@ -6,3 +6,5 @@ type Password string
 const SESSION_COOKIE = "session"
 
 const CONTEXT_AUTH_KEY = "authenticated"
+
+const CONTEXT_LOGIN_KEY = "login"